@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	"golang.org/x/term"
+)
+
+// Detach sequence is the same as the docker one: CTRL-P followed by CTRL-Q
+const (
+	detachPrefix = 0x10
+	detachKey    = 0x11
+)
+
+type AttachCmd struct {
+	UUID string `arg:"positional"`
+}
+
+func (c *AttachCmd) run() {
+	con, client := connect()
+	defer con.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Attach(ctx)
+	if err != nil {
+		log.Fatalf("could not attach to the job: %v", err)
+	}
+
+	err = stream.Send(&api.AttachRequest{
+		Payload: &api.AttachRequest_JobId{JobId: c.UUID},
+	})
+	if err != nil {
+		log.Fatalf("could not attach to the job: %v", err)
+	}
+
+	stdinFd := int(os.Stdin.Fd())
+	if term.IsTerminal(stdinFd) {
+		// Every keystroke has to get to the job as is, so the local
+		// terminal is switched to raw mode while the client is attached
+		oldState, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			log.Fatalf("could not set up the terminal: %v", err)
+		}
+		defer term.Restore(stdinFd, oldState)
+
+		go watchResize(ctx, stream)
+	}
+
+	detached := make(chan struct{})
+	go func() {
+		if passKeystrokes(stream) {
+			close(detached)
+		}
+	}()
+
+	output := make(chan error, 1)
+	go func() {
+		output <- printOutput(stream)
+	}()
+
+	select {
+	case err = <-output:
+		if err != nil {
+			log.Printf("error during the attach session: %v\r\n", err)
+		}
+	case <-detached:
+	}
+}
+
+// passKeystrokes sends the user input to the job until the input
+// is over or the user presses the detach sequence. Returns whether
+// the user has detached
+func passKeystrokes(stream api.TeleWorker_AttachClient) bool {
+	const chunkSize = 1024
+
+	chunk := make([]byte, chunkSize)
+	prefixPressed := false
+	for {
+		n, err := os.Stdin.Read(chunk)
+		for i := 0; i < n; i++ {
+			if prefixPressed && chunk[i] == detachKey {
+				// Send whatever was typed before detaching
+				if i > 0 {
+					sendInput(stream, chunk[:i-1])
+				}
+				return true
+			}
+			prefixPressed = chunk[i] == detachPrefix
+		}
+
+		if n > 0 {
+			sendInput(stream, chunk[:n])
+		}
+		if err != nil {
+			// Let the server know there will be no more input,
+			// but keep printing the output until the job is over
+			stream.CloseSend()
+			return false
+		}
+	}
+}
+
+func sendInput(stream api.TeleWorker_AttachClient, input []byte) {
+	if len(input) == 0 {
+		return
+	}
+
+	cp := make([]byte, len(input))
+	copy(cp, input)
+	stream.Send(&api.AttachRequest{
+		Payload: &api.AttachRequest_Stdin{Stdin: cp},
+	})
+}
+
+// watchResize keeps the job terminal of the same size as the local one
+func watchResize(ctx context.Context, stream api.TeleWorker_AttachClient) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGWINCH)
+	defer signal.Stop(sigCh)
+
+	// The initial size has to be sent as well
+	sigCh <- syscall.SIGWINCH
+	for {
+		select {
+		case <-sigCh:
+			cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+			if err != nil {
+				continue
+			}
+			stream.Send(&api.AttachRequest{
+				Payload: &api.AttachRequest_Resize{Resize: &api.TerminalSize{
+					Rows: uint32(rows),
+					Cols: uint32(cols),
+				}},
+			})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func printOutput(stream api.TeleWorker_AttachClient) error {
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(string(resp.GetOutStream()))
+	}
+}
@@ -15,10 +15,16 @@ import (
 )
 
 var args struct {
-	Start  *StartCmd  `arg:"subcommand:start"`
-	Stop   *StopCmd   `arg:"subcommand:stop"`
-	Status *StatusCmd `arg:"subcommand:status"`
-	Stream *StreamCmd `arg:"subcommand:stream"`
+	Start    *StartCmd    `arg:"subcommand:start"`
+	Stop     *StopCmd     `arg:"subcommand:stop"`
+	Status   *StatusCmd   `arg:"subcommand:status"`
+	Ls       *LsCmd       `arg:"subcommand:ls"`
+	Stream   *StreamCmd   `arg:"subcommand:stream"`
+	Logs     *LogsCmd     `arg:"subcommand:logs"`
+	Attach   *AttachCmd   `arg:"subcommand:attach"`
+	Exec     *ExecCmd     `arg:"subcommand:exec"`
+	Workflow *WorkflowCmd `arg:"subcommand:workflow"`
+	Schedule *ScheduleCmd `arg:"subcommand:schedule"`
 }
 
 func timeoutCtx() (context.Context, context.CancelFunc) {
@@ -37,8 +43,20 @@ func main() {
 		args.Stop.run()
 	case args.Status != nil:
 		args.Status.run()
+	case args.Ls != nil:
+		args.Ls.run()
 	case args.Stream != nil:
 		args.Stream.run()
+	case args.Logs != nil:
+		args.Logs.run()
+	case args.Attach != nil:
+		args.Attach.run()
+	case args.Exec != nil:
+		args.Exec.run()
+	case args.Workflow != nil:
+		args.Workflow.run()
+	case args.Schedule != nil:
+		args.Schedule.run()
 	default:
 		log.Fatalln("command is not supported")
 	}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+)
+
+type LsCmd struct {
+	AllUsers    bool     `arg:"--all-users" help:"list the jobs of all the users, allowed to the admins only"`
+	Owner       string   `help:"list the jobs of the user, allowed to the admins only for the other users"`
+	Status      []string `arg:"separate" help:"list the jobs having the status, e.g. alive or finished"`
+	Label       []string `arg:"-l,--label,separate" help:"list the jobs having the label key=value"`
+	Command     string   `help:"list the jobs whose command line contains the text"`
+	Since       string   `help:"list the jobs created since the time (RFC3339) or the duration ago, e.g. 1h"`
+	Until       string   `help:"list the jobs created until the time (RFC3339) or the duration ago, e.g. 1h"`
+	Limit       int      `default:"50" help:"maximum number of the jobs listed, 0 means unlimited"`
+	OldestFirst bool     `arg:"--oldest-first" help:"list the oldest jobs first"`
+	PageToken   string   `arg:"--page-token" help:"continue the listing stopped at the limit"`
+}
+
+func (c *LsCmd) run() {
+	// pageSize is the number of the jobs requested at once
+	const pageSize = 100
+
+	now := time.Now()
+	since, err := parseMoment(c.Since, now)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	until, err := parseMoment(c.Until, now)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	labels, err := parseLabels(c.Label)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	statuses := make([]api.JobStatus, 0, len(c.Status))
+	for _, s := range c.Status {
+		value, ok := api.JobStatus_value[strings.ToUpper(s)]
+		if !ok {
+			log.Fatalf("unknown job status %s", s)
+		}
+		statuses = append(statuses, api.JobStatus(value))
+	}
+
+	con, client := connect()
+	defer con.Close()
+
+	req := &api.ListJobsRequest{
+		Owner:         c.Owner,
+		AllUsers:      c.AllUsers,
+		Statuses:      statuses,
+		Labels:        labels,
+		Command:       c.Command,
+		CreatedAfter:  since,
+		CreatedBefore: until,
+		PageToken:     c.PageToken,
+	}
+	if c.OldestFirst {
+		req.Order = api.SortOrder_OLDEST_FIRST
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tEXIT\tOWNER\tCREATED\tCOMMAND")
+
+	listed := 0
+	for {
+		req.PageSize = pageSize
+		if c.Limit > 0 && c.Limit-listed < pageSize {
+			req.PageSize = int32(c.Limit - listed)
+		}
+
+		ctx, cancel := timeoutCtx()
+		resp, err := client.ListJobs(ctx, req)
+		cancel()
+		if err != nil {
+			log.Fatalf("could not list the jobs: %v", err)
+		}
+
+		for _, job := range resp.GetJobs() {
+			printSummary(w, job, now)
+		}
+		listed += len(resp.GetJobs())
+
+		req.PageToken = resp.GetNextPageToken()
+		if req.PageToken == "" || c.Limit > 0 && listed >= c.Limit {
+			break
+		}
+	}
+	w.Flush()
+
+	if req.PageToken != "" {
+		fmt.Fprintf(os.Stderr, "more jobs are available, continue with --page-token %s\n", req.PageToken)
+	}
+}
+
+func printSummary(w *tabwriter.Writer, job *api.JobSummary, now time.Time) {
+	exitCode := "-"
+	if job.GetExitedAt() != nil {
+		exitCode = fmt.Sprint(job.GetExitCode())
+	}
+
+	created := job.GetCreatedAt().AsTime().Local()
+	createdAt := created.Format("Jan 02 15:04:05")
+	if now.Sub(created) < 24*time.Hour {
+		createdAt = created.Format("15:04:05")
+	}
+
+	commandLine := strings.Join(append([]string{job.GetCommand()}, job.GetArgs()...), " ")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		job.GetJobId(),
+		strings.ToLower(job.GetStatus().String()),
+		exitCode,
+		job.GetOwner(),
+		createdAt,
+		commandLine,
+	)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	"golang.org/x/term"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/protobuf/proto"
+)
+
+type LogsCmd struct {
+	Export *LogsExportCmd `arg:"subcommand:export"`
+	Stream *LogsStreamCmd `arg:"subcommand:stream"`
+}
+
+type LogsExportCmd struct {
+	Output string `arg:"-o,--output,required" help:"file the gzipped tar archive is written to"`
+	UUID   string `arg:"positional,required"`
+}
+
+type LogsStreamCmd struct {
+	Selector []string `arg:"-l,--selector,separate" help:"stream the jobs having the label key=value, including the ones started later"`
+	Err      bool
+	All      bool     `help:"stream both stdout and stderr of every job"`
+	Tail     int32    `help:"number of the last lines of the current output of every job to show"`
+	NoFollow bool     `arg:"--no-follow" help:"show the current output only, without waiting for more"`
+	UUIDs    []string `arg:"positional"`
+}
+
+func (c *LogsCmd) run() {
+	switch {
+	case c.Export != nil:
+		c.Export.run()
+	case c.Stream != nil:
+		c.Stream.run()
+	default:
+		log.Fatalln("command is not supported")
+	}
+}
+
+func (c *LogsExportCmd) run() {
+	con, client := connect()
+	defer con.Close()
+
+	r, err := client.DownloadLogs(context.Background(), &api.DownloadLogsRequest{JobId: c.UUID})
+	if err != nil {
+		log.Fatalf("could not download the logs: %v", err)
+	}
+
+	f, err := os.Create(c.Output)
+	if err != nil {
+		log.Fatalf("could not create the archive file: %v", err)
+	}
+
+	size, err := receiveArchive(r, f)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		// The incomplete archive is of no use
+		f.Close()
+		os.Remove(c.Output)
+		log.Fatalf("could not download the logs: %v", err)
+	}
+
+	fmt.Printf("%d bytes written to %s\n", size, c.Output)
+}
+
+// receiveArchive writes the archive pieces to the file until the stream is over
+func receiveArchive(r api.TeleWorker_DownloadLogsClient, w io.Writer) (int64, error) {
+	var size int64
+	for {
+		resp, err := r.Recv()
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return size, err
+		}
+
+		n, err := w.Write(resp.GetData())
+		size += int64(n)
+		if err != nil {
+			return size, err
+		}
+	}
+}
+
+func (c *LogsStreamCmd) run() {
+	selector, err := parseLabels(c.Selector)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	con, client := connect()
+	defer con.Close()
+
+	r, err := client.StreamMany(context.Background(), &api.StreamManyRequest{
+		JobIds:       c.UUIDs,
+		Selector:     selector,
+		StreamErrors: c.Err,
+		Combined:     c.All,
+		Follow:       proto.Bool(!c.NoFollow),
+		TailLines:    c.Tail,
+	}, grpc.UseCompressor(gzip.Name))
+	if err != nil {
+		log.Fatalf("could not stream the logs: %v", err)
+	}
+
+	p := newJobPrinter(term.IsTerminal(int(os.Stdout.Fd())))
+	for {
+		resp, err := r.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatalf("error during the stream: %v", err)
+		}
+
+		if truncated := resp.GetTruncated(); truncated > 0 {
+			p.print(resp.GetJobName(), []byte(fmt.Sprintf("--- %d bytes truncated ---\n", truncated)))
+		}
+		if data := resp.GetOutStream(); len(data) > 0 {
+			p.print(resp.GetJobName(), data)
+		}
+	}
+}
+
+// jobPrinter prints the lines of several jobs prefixed
+// with the job names, which are aligned and colored
+type jobPrinter struct {
+	colored bool
+	// width is the length of the longest name seen so far
+	width int
+	// colors are assigned to the jobs in the order they appear
+	colors map[string]string
+}
+
+func newJobPrinter(colored bool) *jobPrinter {
+	return &jobPrinter{
+		colored: colored,
+		colors:  make(map[string]string),
+	}
+}
+
+func (p *jobPrinter) print(name string, line []byte) {
+	palette := []string{"\x1b[36m", "\x1b[33m", "\x1b[32m", "\x1b[35m", "\x1b[34m", "\x1b[96m", "\x1b[93m", "\x1b[92m"}
+	const reset = "\x1b[0m"
+
+	if len(name) > p.width {
+		p.width = len(name)
+	}
+	prefix := fmt.Sprintf("%-*s | ", p.width, name)
+	if p.colored {
+		color, ok := p.colors[name]
+		if !ok {
+			color = palette[len(p.colors)%len(palette)]
+			p.colors[name] = color
+		}
+		prefix = color + prefix + reset
+	}
+
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		// The last line of the output might have no line break
+		line = append(line, '\n')
+	}
+	fmt.Print(prefix)
+	os.Stdout.Write(line)
+}
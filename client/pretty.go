@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The fields of the JSON lines which are printed in the special way
+var (
+	timeFields    = []string{"time", "ts", "timestamp"}
+	levelFields   = []string{"level", "lvl", "severity"}
+	messageFields = []string{"msg", "message"}
+)
+
+// prettyLine converts the JSON line to the human readable form, i.e. the
+// time, the level and the message followed by the rest of the fields as
+// key=value pairs. The lines which are not JSON objects are left as they are
+func prettyLine(line []byte) []byte {
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+
+	var obj map[string]interface{}
+	if d.Decode(&obj) != nil || obj == nil {
+		return line
+	}
+
+	var parts []string
+	if t, ok := takeField(obj, timeFields); ok {
+		parts = append(parts, t)
+	}
+	if level, ok := takeField(obj, levelFields); ok {
+		parts = append(parts, fmt.Sprintf("%-5s", strings.ToUpper(level)))
+	}
+	if msg, ok := takeField(obj, messageFields); ok {
+		parts = append(parts, msg)
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := fieldText(obj[key])
+		if strings.ContainsAny(value, " \t\"") {
+			value = fmt.Sprintf("%q", value)
+		}
+		parts = append(parts, key+"="+value)
+	}
+
+	return []byte(strings.Join(parts, " ") + "\n")
+}
+
+// takeField removes the first of the fields found in the object
+// returning its value
+func takeField(obj map[string]interface{}, fields []string) (string, bool) {
+	for _, field := range fields {
+		value, ok := obj[field]
+		if ok {
+			delete(obj, field)
+			return fieldText(value), true
+		}
+	}
+	return "", false
+}
+
+// fieldText returns the textual representation of the field value
+func fieldText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
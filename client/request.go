@@ -5,16 +5,27 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	api "github.com/spirifoxy/teleworker/internal/api/v1"
 )
 
 type StartCmd struct {
-	Command string `arg:"required"`
-	CPU     int32
-	Mem     int32
-	IO      int32
-	Args    []string `arg:"positional"`
+	Command     string `arg:"required"`
+	CPU         int32
+	Mem         int32
+	IO          int32
+	StdinFile   string `arg:"--stdin-file"`
+	TTY         bool
+	Restart     string        `help:"never, on-failure or always"`
+	MaxRestarts int32         `arg:"--max-restarts"`
+	Backoff     time.Duration `help:"delay before the first restart, doubled after every attempt"`
+	Priority    int32         `help:"nice value from -20 (the highest) to 19 (the lowest)"`
+	LogFormat   string        `arg:"--log-format" help:"format of the output lines: text or json"`
+	Label       []string      `arg:"-l,--label,separate" help:"label of the job as key=value, the name label names the job in the streams of several jobs"`
+	Args        []string      `arg:"positional"`
 }
 type StopCmd struct {
 	UUID string `arg:"positional"`
@@ -24,25 +35,61 @@ type StatusCmd struct {
 	UUID string `arg:"positional"`
 }
 
-type StreamCmd struct {
-	Err  bool
-	UUID string `arg:"positional"`
+type ExecCmd struct {
+	Command string   `arg:"required"`
+	UUID    string   `arg:"positional,required"`
+	Args    []string `arg:"positional"`
 }
 
 func (c *StartCmd) run() {
 	con, client := connect()
 	defer con.Close()
 
+	restartPolicy, err := parseRestartPolicy(c.Restart)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	logFormat, err := parseLogFormat(c.LogFormat)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	labels, err := parseLabels(c.Label)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	req := &api.StartRequest{
+		Command:          c.Command,
+		Args:             c.Args,
+		CpuWeight:        c.CPU,
+		IoWeight:         c.IO,
+		MemoryLimitMb:    c.Mem,
+		Tty:              c.TTY,
+		RestartPolicy:    restartPolicy,
+		MaxRestarts:      c.MaxRestarts,
+		RestartBackoffMs: int32(c.Backoff.Milliseconds()),
+		Priority:         c.Priority,
+		LogFormat:        logFormat,
+		Labels:           labels,
+	}
+
+	input, err := c.input()
+	if err != nil {
+		log.Fatalf("could not read the job input: %v", err)
+	}
+
+	if input != nil {
+		defer input.Close()
+		err = startWithInput(client, req, input)
+		if err != nil {
+			log.Fatalf("could not start the job: %v", err)
+		}
+		return
+	}
+
 	ctx, cancel := timeoutCtx()
 	defer cancel()
-
-	r, err := client.Start(ctx, &api.StartRequest{
-		Command:       c.Command,
-		Args:          c.Args,
-		CpuWeight:     c.CPU,
-		IoWeight:      c.IO,
-		MemoryLimitMb: c.Mem,
-	})
+	r, err := client.Start(ctx, req)
 	if err != nil {
 		log.Fatalf("could not start the job: %v", err)
 	}
@@ -50,6 +97,145 @@ func (c *StartCmd) run() {
 	fmt.Println(r.GetJobId())
 }
 
+// parseRestartPolicy converts the policy provided by the user,
+// e.g. "on-failure", to its API representation
+func parseRestartPolicy(restart string) (api.RestartPolicy, error) {
+	if restart == "" {
+		return api.RestartPolicy_NEVER, nil
+	}
+
+	name := strings.ToUpper(strings.ReplaceAll(restart, "-", "_"))
+	policy, ok := api.RestartPolicy_value[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown restart policy %s", restart)
+	}
+	return api.RestartPolicy(policy), nil
+}
+
+// parseLogFormat converts the log format provided by the user,
+// e.g. "json", to its API representation
+func parseLogFormat(format string) (api.LogFormat, error) {
+	if format == "" {
+		return api.LogFormat_TEXT, nil
+	}
+
+	value, ok := api.LogFormat_value[strings.ToUpper(format)]
+	if !ok {
+		return 0, fmt.Errorf("unknown log format %s", format)
+	}
+	return api.LogFormat(value), nil
+}
+
+// parseLabels converts the key=value pairs to the labels map
+func parseLabels(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid label %s, expected key=value", pair)
+		}
+		labels[pair[:i]] = pair[i+1:]
+	}
+	return labels, nil
+}
+
+// input returns the source of the job stdin, which is either the file
+// provided by the user or the data piped to the client itself.
+// Nil is returned if there is no input for the job
+func (c *StartCmd) input() (io.ReadCloser, error) {
+	if c.StdinFile != "" {
+		return os.Open(c.StdinFile)
+	}
+	if c.TTY {
+		// The input for such jobs is provided by attaching to them
+		return nil, nil
+	}
+
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Mode()&os.ModeCharDevice != 0 {
+		// Stdin is a terminal, so nothing was piped
+		return nil, nil
+	}
+	return os.Stdin, nil
+}
+
+// jobIDHeader is the header the server sends the job ID in
+// as soon as the job is created, see server.JobIDHeader
+const jobIDHeader = "job-id"
+
+// startWithInput starts the job and streams the input to it by chunks,
+// printing the job ID as soon as the job is created. There is no
+// timeout here as the input might be as large as the user wants
+func startWithInput(client api.TeleWorkerClient, req *api.StartRequest, input io.Reader) error {
+	const chunkSize = 32 * 1024
+
+	stream, err := client.StartWithInput(context.Background())
+	if err != nil {
+		return err
+	}
+
+	err = stream.Send(&api.StartWithInputRequest{
+		Payload: &api.StartWithInputRequest_Start{Start: req},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The job might be queued, so the input is not accepted for a while,
+	// but the user is able to watch or stop the job meanwhile
+	header, err := stream.Header()
+	if err != nil {
+		return err
+	}
+	printed := false
+	if ids := header.Get(jobIDHeader); len(ids) > 0 {
+		fmt.Println(ids[0])
+		printed = true
+	}
+
+	chunk := make([]byte, chunkSize)
+	for {
+		n, readErr := input.Read(chunk)
+		if n > 0 {
+			err = stream.Send(&api.StartWithInputRequest{
+				Payload: &api.StartWithInputRequest_Stdin{Stdin: chunk[:n]},
+			})
+			if err == io.EOF {
+				// The server stopped accepting the input, the
+				// actual result is to be received below
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	r, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	if !printed {
+		// The older servers send the ID only once the input is over
+		fmt.Println(r.GetJobId())
+	}
+	return nil
+}
+
 func (c *StopCmd) run() {
 	con, client := connect()
 	defer con.Close()
@@ -82,29 +268,33 @@ func (c *StatusCmd) run() {
 	fmt.Println(r.String())
 }
 
-func (c *StreamCmd) run() {
+func (c *ExecCmd) run() {
 	con, client := connect()
 	defer con.Close()
 
-	r, err := client.Stream(context.Background(), &api.StreamRequest{
-		JobId:        c.UUID,
-		StreamErrors: c.Err,
+	r, err := client.Exec(context.Background(), &api.ExecRequest{
+		JobId:   c.UUID,
+		Command: c.Command,
+		Args:    c.Args,
 	})
 	if err != nil {
-		log.Fatalf("could not start streaming: %v", err)
+		log.Fatalf("could not exec the command: %v", err)
 	}
 
 	for {
 		resp, err := r.Recv()
-		if err == io.EOF {
-			fmt.Println("EOF")
-			break
-		}
 		if err != nil {
-			log.Fatalf("error during the stream: %v", err)
+			log.Fatalf("error during the exec: %v", err)
 		}
 
-		pack := resp.GetOutStream()
-		fmt.Print(string(pack))
+		switch payload := resp.GetPayload().(type) {
+		case *api.ExecResponse_OutStream:
+			os.Stdout.Write(payload.OutStream)
+		case *api.ExecResponse_ErrStream:
+			os.Stderr.Write(payload.ErrStream)
+		case *api.ExecResponse_ExitCode:
+			// Behave as if the command was run locally
+			os.Exit(int(payload.ExitCode))
+		}
 	}
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+)
+
+type ScheduleCmd struct {
+	Create *ScheduleCreateCmd `arg:"subcommand:create"`
+	List   *ScheduleListCmd   `arg:"subcommand:ls"`
+	Delete *ScheduleDeleteCmd `arg:"subcommand:rm"`
+}
+
+type ScheduleCreateCmd struct {
+	Cron        string `arg:"required" help:"standard 5-field cron expression, e.g. \"0 2 * * *\""`
+	TZ          string `help:"timezone the expression is evaluated in, e.g. Europe/Prague"`
+	Overlap     string `help:"skip, queue or allow"`
+	Command     string `arg:"required"`
+	CPU         int32
+	Mem         int32
+	IO          int32
+	Restart     string        `help:"never, on-failure or always"`
+	MaxRestarts int32         `arg:"--max-restarts"`
+	Backoff     time.Duration `help:"delay before the first restart, doubled after every attempt"`
+	Priority    int32         `help:"nice value from -20 (the highest) to 19 (the lowest)"`
+	LogFormat   string        `arg:"--log-format" help:"format of the output lines: text or json"`
+	Label       []string      `arg:"-l,--label,separate" help:"label of the started jobs as key=value"`
+	Args        []string      `arg:"positional"`
+}
+
+type ScheduleListCmd struct{}
+
+type ScheduleDeleteCmd struct {
+	UUID string `arg:"positional,required"`
+}
+
+func (c *ScheduleCmd) run() {
+	switch {
+	case c.Create != nil:
+		c.Create.run()
+	case c.List != nil:
+		c.List.run()
+	case c.Delete != nil:
+		c.Delete.run()
+	default:
+		log.Fatalln("command is not supported")
+	}
+}
+
+func (c *ScheduleCreateCmd) run() {
+	restartPolicy, err := parseRestartPolicy(c.Restart)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	overlapPolicy, err := parseOverlapPolicy(c.Overlap)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	logFormat, err := parseLogFormat(c.LogFormat)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	labels, err := parseLabels(c.Label)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	con, client := connect()
+	defer con.Close()
+
+	ctx, cancel := timeoutCtx()
+	defer cancel()
+
+	r, err := client.CreateSchedule(ctx, &api.CreateScheduleRequest{
+		Cron:          c.Cron,
+		Timezone:      c.TZ,
+		OverlapPolicy: overlapPolicy,
+		Job: &api.StartRequest{
+			Command:          c.Command,
+			Args:             c.Args,
+			CpuWeight:        c.CPU,
+			IoWeight:         c.IO,
+			MemoryLimitMb:    c.Mem,
+			RestartPolicy:    restartPolicy,
+			MaxRestarts:      c.MaxRestarts,
+			RestartBackoffMs: int32(c.Backoff.Milliseconds()),
+			Priority:         c.Priority,
+			LogFormat:        logFormat,
+			Labels:           labels,
+		},
+	})
+	if err != nil {
+		log.Fatalf("could not create the schedule: %v", err)
+	}
+
+	fmt.Println(r.GetScheduleId())
+}
+
+// parseOverlapPolicy converts the policy provided by the user,
+// e.g. "queue", to its API representation
+func parseOverlapPolicy(overlap string) (api.OverlapPolicy, error) {
+	if overlap == "" {
+		return api.OverlapPolicy_SKIP, nil
+	}
+
+	policy, ok := api.OverlapPolicy_value[strings.ToUpper(overlap)]
+	if !ok {
+		return 0, fmt.Errorf("unknown overlap policy %s", overlap)
+	}
+	return api.OverlapPolicy(policy), nil
+}
+
+func (c *ScheduleListCmd) run() {
+	con, client := connect()
+	defer con.Close()
+
+	ctx, cancel := timeoutCtx()
+	defer cancel()
+
+	r, err := client.ListSchedules(ctx, &api.ListSchedulesRequest{})
+	if err != nil {
+		log.Fatalf("could not list the schedules: %v", err)
+	}
+
+	for _, sc := range r.GetSchedules() {
+		tz := sc.GetTimezone()
+		if tz == "" {
+			tz = "server time"
+		}
+		fmt.Printf("%s  %q (%s), overlap %s: %s %s\n",
+			sc.GetScheduleId(), sc.GetCron(), tz,
+			strings.ToLower(sc.GetOverlapPolicy().String()),
+			sc.GetJob().GetCommand(), strings.Join(sc.GetJob().GetArgs(), " "),
+		)
+		fmt.Printf("  next run: %s\n", sc.GetNextRun().AsTime().Local().Format(time.RFC3339))
+		for _, run := range sc.GetHistory() {
+			result := run.GetJobId()
+			if run.GetError() != "" {
+				result = "failed: " + run.GetError()
+			}
+			fmt.Printf("  %s  %s\n", run.GetStartedAt().AsTime().Local().Format(time.RFC3339), result)
+		}
+	}
+}
+
+func (c *ScheduleDeleteCmd) run() {
+	con, client := connect()
+	defer con.Close()
+
+	ctx, cancel := timeoutCtx()
+	defer cancel()
+
+	_, err := client.DeleteSchedule(ctx, &api.DeleteScheduleRequest{
+		ScheduleId: c.UUID,
+	})
+	if err != nil {
+		log.Fatalf("could not delete the schedule: %v", err)
+	}
+}
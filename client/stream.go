@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	"golang.org/x/term"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type StreamCmd struct {
+	Err        bool
+	All        bool     `help:"stream both stdout and stderr in the order they were produced"`
+	Tail       int32    `help:"number of the last lines of the current output to show"`
+	TailBytes  int64    `arg:"--tail-bytes" help:"number of the last bytes of the current output to show"`
+	NoFollow   bool     `arg:"--no-follow" help:"show the current output only, without waiting for more"`
+	Timestamps bool     `help:"prefix every line with the time it was produced at"`
+	Since      string   `help:"show the output produced since the time (RFC3339) or the duration ago, e.g. 10m"`
+	Until      string   `help:"show the output produced until the time (RFC3339) or the duration ago, e.g. 10m"`
+	Include    string   `help:"show only the lines matching the regular expression"`
+	Exclude    string   `help:"hide the lines matching the regular expression"`
+	Context    int32    `help:"number of the lines to show around the matching ones"`
+	Where      []string `arg:"separate" help:"show only the JSON lines matching the predicate, e.g. level=error or latency_ms>500"`
+	Fields     []string `help:"show only the listed fields of the JSON lines"`
+	Pretty     bool     `help:"print the JSON lines in human readable form"`
+	UUID       string   `arg:"positional"`
+}
+
+func (c *StreamCmd) run() {
+	// The stream is resumed from the place it was interrupted
+	// at, unless the connection can't be restored for too long
+	const (
+		maxRetries = 10
+		retryDelay = time.Second
+	)
+
+	now := time.Now()
+	since, err := parseMoment(c.Since, now)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	until, err := parseMoment(c.Until, now)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	con, client := connect()
+	defer con.Close()
+
+	p := &printer{
+		timestamps: c.Timestamps,
+		colored:    c.All && term.IsTerminal(int(os.Stdout.Fd())),
+		separated:  c.Context > 0 && (c.Include != "" || c.Exclude != "" || len(c.Where) > 0),
+		ends:       make(map[api.OutputSource]int64),
+		pretty:     c.Pretty,
+		partial:    make(map[bool][]byte),
+	}
+	pos := position{since: since, until: until}
+	for retries := 0; ; retries++ {
+		received, err := c.stream(client, p, &pos)
+		if err == nil {
+			return
+		}
+		if received {
+			retries = 0
+		}
+
+		// The stream is also interrupted by the server
+		// if it doesn't keep up with the job output
+		code := status.Code(err)
+		if code != codes.Unavailable && code != codes.ResourceExhausted || retries >= maxRetries {
+			log.Fatalf("error during the stream: %v", err)
+		}
+		if c.All {
+			log.Printf("stream interrupted, resuming from chunk %d", pos.seq)
+		} else {
+			log.Printf("stream interrupted, resuming from offset %d", pos.offset)
+		}
+		time.Sleep(retryDelay)
+	}
+}
+
+// position is the place in the output the stream is to be resumed from:
+// the offset for the single output, the sequence number of the last piece
+// of the combined one along with the number of its bytes already received
+type position struct {
+	offset int64
+	seq    uint64
+	done   int64
+	// since and until limit the stream regardless of the position
+	since *timestamppb.Timestamp
+	until *timestamppb.Timestamp
+}
+
+// parseMoment converts the time provided by the user, either RFC3339
+// or the duration before now, to its API representation
+func parseMoment(value string, now time.Time) (*timestamppb.Timestamp, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return timestamppb.New(now.Add(-d)), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %s, expected RFC3339 time or duration", value)
+	}
+	return timestamppb.New(t), nil
+}
+
+// stream prints the output starting from the position and moves it
+// forward as the output comes. Returns whether anything was received
+func (c *StreamCmd) stream(client api.TeleWorkerClient, p *printer, pos *position) (bool, error) {
+	req := &api.StreamRequest{
+		JobId:        c.UUID,
+		StreamErrors: c.Err,
+		Combined:     c.All,
+		FromOffset:   pos.offset,
+		Follow:       proto.Bool(!c.NoFollow),
+		Since:        pos.since,
+		Until:        pos.until,
+		Include:      c.Include,
+		Exclude:      c.Exclude,
+		ContextLines: c.Context,
+		Where:        c.Where,
+		Fields:       c.Fields,
+	}
+	if c.All {
+		req.FromSeq = pos.seq
+	}
+	if pos.offset == 0 && pos.seq == 0 {
+		// The tail applies only to the beginning of the
+		// stream, the resumed one continues from the offset
+		req.TailLines = c.Tail
+		req.TailBytes = c.TailBytes
+	}
+
+	// The output is mostly text, so it is worth compressing
+	r, err := client.Stream(context.Background(), req, grpc.UseCompressor(gzip.Name))
+	if err != nil {
+		return false, err
+	}
+
+	// The combined stream resumes from the beginning of the last piece,
+	// so the part of it which was already received is skipped
+	resumed, skip := pos.seq, pos.done
+
+	received := false
+	for {
+		resp, err := r.Recv()
+		if err == io.EOF {
+			p.flush()
+			fmt.Println("EOF")
+			return received, nil
+		}
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		if truncated := resp.GetTruncated(); truncated > 0 {
+			fmt.Printf("--- %d bytes truncated ---\n", truncated)
+		}
+		pack := resp.GetOutStream()
+		if seq := resp.GetSeq(); c.All && seq > 0 {
+			if seq == resumed && skip > 0 {
+				n := skip
+				if n > int64(len(pack)) {
+					n = int64(len(pack))
+				}
+				skip -= n
+				pack = pack[n:]
+			}
+			if seq != pos.seq {
+				pos.seq, pos.done = seq, 0
+			}
+			pos.done += int64(len(pack))
+		}
+
+		p.separate(resp.GetSource(), resp.GetOffset(), len(resp.GetOutStream()))
+		p.print(pack, resp.GetSource() == api.OutputSource_STDERR, resp.GetTimestamp())
+
+		size := resp.GetSize()
+		if size == 0 {
+			size = int64(len(resp.GetOutStream()))
+		}
+		pos.offset = resp.GetOffset() + size
+	}
+}
+
+// printer prints the output optionally prefixing the
+// lines with their time and highlighting stderr
+type printer struct {
+	timestamps bool
+	colored    bool
+	// midLine tells whether the last printed line is not yet complete
+	midLine bool
+	// separated tells whether the groups of the filtered lines are
+	// separated, ends contains where the last line of every source ends
+	separated bool
+	ends      map[api.OutputSource]int64
+	// pretty tells whether the JSON lines are printed in human readable
+	// form, partial contains the incomplete lines of stdout and stderr
+	pretty  bool
+	partial map[bool][]byte
+	// last is the time of the last printed chunk
+	last *timestamppb.Timestamp
+}
+
+// separate prints the separator like grep does if the filtered
+// line doesn't follow the previous one of the same source
+func (p *printer) separate(source api.OutputSource, offset int64, size int) {
+	if !p.separated {
+		return
+	}
+
+	end, ok := p.ends[source]
+	if ok && end != offset {
+		fmt.Println("--")
+	}
+	p.ends[source] = offset + int64(size)
+}
+
+func (p *printer) print(pack []byte, stderr bool, t *timestamppb.Timestamp) {
+	p.last = t
+	if !p.pretty {
+		p.write(pack, stderr, t)
+		return
+	}
+
+	// The lines are printed once they are complete
+	data := append(p.partial[stderr], pack...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		p.write(prettyLine(data[:i+1]), stderr, t)
+		data = data[i+1:]
+	}
+	p.partial[stderr] = append([]byte(nil), data...)
+}
+
+// flush prints the incomplete lines as they are, e.g. once the output is over
+func (p *printer) flush() {
+	for stderr, data := range p.partial {
+		if len(data) > 0 {
+			p.write(prettyLine(data), stderr, p.last)
+		}
+		delete(p.partial, stderr)
+	}
+}
+
+func (p *printer) write(pack []byte, stderr bool, t *timestamppb.Timestamp) {
+	const (
+		red   = "\x1b[31m"
+		reset = "\x1b[0m"
+	)
+
+	if p.colored && stderr {
+		fmt.Print(red)
+		defer fmt.Print(reset)
+	}
+	if !p.timestamps {
+		os.Stdout.Write(pack)
+		return
+	}
+
+	prefix := t.AsTime().Local().Format(time.RFC3339Nano) + " "
+	for len(pack) > 0 {
+		if !p.midLine {
+			fmt.Print(prefix)
+		}
+		line := pack
+		if i := bytes.IndexByte(pack, '\n'); i >= 0 {
+			line = pack[:i+1]
+		}
+		os.Stdout.Write(line)
+		pack = pack[len(line):]
+		p.midLine = line[len(line)-1] != '\n'
+	}
+}
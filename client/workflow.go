@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	"gopkg.in/yaml.v3"
+)
+
+type WorkflowCmd struct {
+	Submit *WorkflowSubmitCmd `arg:"subcommand:submit"`
+	Status *WorkflowStatusCmd `arg:"subcommand:status"`
+}
+
+type WorkflowSubmitCmd struct {
+	File string `arg:"positional,required" help:"workflow description in YAML or JSON"`
+}
+
+type WorkflowStatusCmd struct {
+	UUID string `arg:"positional,required"`
+}
+
+// workflowSpec is the format of the workflow description file.
+// As JSON is a subset of YAML, both of them are supported
+type workflowSpec struct {
+	Steps []struct {
+		Name        string            `yaml:"name"`
+		Command     string            `yaml:"command"`
+		Args        []string          `yaml:"args"`
+		CPU         int32             `yaml:"cpu"`
+		Mem         int32             `yaml:"mem"`
+		IO          int32             `yaml:"io"`
+		Restart     string            `yaml:"restart"`
+		MaxRestarts int32             `yaml:"max_restarts"`
+		Backoff     time.Duration     `yaml:"backoff"`
+		Priority    int32             `yaml:"priority"`
+		LogFormat   string            `yaml:"log_format"`
+		Labels      map[string]string `yaml:"labels"`
+		DependsOn   []string          `yaml:"depends_on"`
+		// Policy is either "on-success" (default) or "always"
+		Policy string `yaml:"policy"`
+	} `yaml:"steps"`
+}
+
+func (c *WorkflowCmd) run() {
+	switch {
+	case c.Submit != nil:
+		c.Submit.run()
+	case c.Status != nil:
+		c.Status.run()
+	default:
+		log.Fatalln("command is not supported")
+	}
+}
+
+func (c *WorkflowSubmitCmd) run() {
+	req, err := c.request()
+	if err != nil {
+		log.Fatalf("could not read the workflow: %v", err)
+	}
+
+	con, client := connect()
+	defer con.Close()
+
+	ctx, cancel := timeoutCtx()
+	defer cancel()
+
+	r, err := client.SubmitWorkflow(ctx, req)
+	if err != nil {
+		log.Fatalf("could not submit the workflow: %v", err)
+	}
+
+	fmt.Println(r.GetWorkflowId())
+}
+
+// request builds the submit request from the workflow description file
+func (c *WorkflowSubmitCmd) request() (*api.SubmitWorkflowRequest, error) {
+	data, err := ioutil.ReadFile(c.File)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec workflowSpec
+	err = yaml.Unmarshal(data, &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &api.SubmitWorkflowRequest{}
+	for _, step := range spec.Steps {
+		restartPolicy, err := parseRestartPolicy(step.Restart)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", step.Name, err)
+		}
+		logFormat, err := parseLogFormat(step.LogFormat)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", step.Name, err)
+		}
+
+		var policy api.DependencyPolicy
+		switch step.Policy {
+		case "", "on-success":
+			policy = api.DependencyPolicy_ON_SUCCESS
+		case "always", "on-completion":
+			policy = api.DependencyPolicy_ON_COMPLETION
+		default:
+			return nil, fmt.Errorf("step %s: unknown dependency policy %s", step.Name, step.Policy)
+		}
+
+		req.Steps = append(req.Steps, &api.WorkflowStep{
+			Name: step.Name,
+			Job: &api.StartRequest{
+				Command:          step.Command,
+				Args:             step.Args,
+				CpuWeight:        step.CPU,
+				IoWeight:         step.IO,
+				MemoryLimitMb:    step.Mem,
+				RestartPolicy:    restartPolicy,
+				MaxRestarts:      step.MaxRestarts,
+				RestartBackoffMs: int32(step.Backoff.Milliseconds()),
+				Priority:         step.Priority,
+				LogFormat:        logFormat,
+				Labels:           step.Labels,
+			},
+			DependsOn: step.DependsOn,
+			Policy:    policy,
+		})
+	}
+
+	return req, nil
+}
+
+func (c *WorkflowStatusCmd) run() {
+	con, client := connect()
+	defer con.Close()
+
+	ctx, cancel := timeoutCtx()
+	defer cancel()
+
+	r, err := client.WorkflowStatus(ctx, &api.WorkflowStatusRequest{
+		WorkflowId: c.UUID,
+	})
+	if err != nil {
+		log.Fatalf("could not get the workflow status: %v", err)
+	}
+
+	fmt.Printf("Workflow: %s\n", r.GetState())
+	for _, step := range r.GetSteps() {
+		fmt.Printf("%s: %s", step.GetName(), step.GetState())
+		if step.GetJobId() != "" {
+			fmt.Printf(", job %s, exit code %d", step.GetJobId(), step.GetExitCode())
+		}
+		if step.GetError() != "" {
+			fmt.Printf(", error: %s", step.GetError())
+		}
+		fmt.Println()
+	}
+}
@@ -19,9 +19,20 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TeleWorkerClient interface {
 	Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*StartResponse, error)
+	StartWithInput(ctx context.Context, opts ...grpc.CallOption) (TeleWorker_StartWithInputClient, error)
 	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error)
 	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
 	Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (TeleWorker_StreamClient, error)
+	StreamMany(ctx context.Context, in *StreamManyRequest, opts ...grpc.CallOption) (TeleWorker_StreamManyClient, error)
+	DownloadLogs(ctx context.Context, in *DownloadLogsRequest, opts ...grpc.CallOption) (TeleWorker_DownloadLogsClient, error)
+	Attach(ctx context.Context, opts ...grpc.CallOption) (TeleWorker_AttachClient, error)
+	Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (TeleWorker_ExecClient, error)
+	SubmitWorkflow(ctx context.Context, in *SubmitWorkflowRequest, opts ...grpc.CallOption) (*SubmitWorkflowResponse, error)
+	WorkflowStatus(ctx context.Context, in *WorkflowStatusRequest, opts ...grpc.CallOption) (*WorkflowStatusResponse, error)
+	CreateSchedule(ctx context.Context, in *CreateScheduleRequest, opts ...grpc.CallOption) (*CreateScheduleResponse, error)
+	ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ListSchedulesResponse, error)
+	DeleteSchedule(ctx context.Context, in *DeleteScheduleRequest, opts ...grpc.CallOption) (*DeleteScheduleResponse, error)
 }
 
 type teleWorkerClient struct {
@@ -41,6 +52,40 @@ func (c *teleWorkerClient) Start(ctx context.Context, in *StartRequest, opts ...
 	return out, nil
 }
 
+func (c *teleWorkerClient) StartWithInput(ctx context.Context, opts ...grpc.CallOption) (TeleWorker_StartWithInputClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TeleWorker_ServiceDesc.Streams[0], "/v1.TeleWorker/StartWithInput", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &teleWorkerStartWithInputClient{stream}
+	return x, nil
+}
+
+type TeleWorker_StartWithInputClient interface {
+	Send(*StartWithInputRequest) error
+	CloseAndRecv() (*StartResponse, error)
+	grpc.ClientStream
+}
+
+type teleWorkerStartWithInputClient struct {
+	grpc.ClientStream
+}
+
+func (x *teleWorkerStartWithInputClient) Send(m *StartWithInputRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *teleWorkerStartWithInputClient) CloseAndRecv() (*StartResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(StartResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *teleWorkerClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error) {
 	out := new(StopResponse)
 	err := c.cc.Invoke(ctx, "/v1.TeleWorker/Stop", in, out, opts...)
@@ -59,8 +104,17 @@ func (c *teleWorkerClient) Status(ctx context.Context, in *StatusRequest, opts .
 	return out, nil
 }
 
+func (c *teleWorkerClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, "/v1.TeleWorker/ListJobs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *teleWorkerClient) Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (TeleWorker_StreamClient, error) {
-	stream, err := c.cc.NewStream(ctx, &TeleWorker_ServiceDesc.Streams[0], "/v1.TeleWorker/Stream", opts...)
+	stream, err := c.cc.NewStream(ctx, &TeleWorker_ServiceDesc.Streams[1], "/v1.TeleWorker/Stream", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -91,14 +145,197 @@ func (x *teleWorkerStreamClient) Recv() (*StreamResponse, error) {
 	return m, nil
 }
 
+func (c *teleWorkerClient) StreamMany(ctx context.Context, in *StreamManyRequest, opts ...grpc.CallOption) (TeleWorker_StreamManyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TeleWorker_ServiceDesc.Streams[2], "/v1.TeleWorker/StreamMany", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &teleWorkerStreamManyClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TeleWorker_StreamManyClient interface {
+	Recv() (*StreamManyResponse, error)
+	grpc.ClientStream
+}
+
+type teleWorkerStreamManyClient struct {
+	grpc.ClientStream
+}
+
+func (x *teleWorkerStreamManyClient) Recv() (*StreamManyResponse, error) {
+	m := new(StreamManyResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *teleWorkerClient) DownloadLogs(ctx context.Context, in *DownloadLogsRequest, opts ...grpc.CallOption) (TeleWorker_DownloadLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TeleWorker_ServiceDesc.Streams[3], "/v1.TeleWorker/DownloadLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &teleWorkerDownloadLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TeleWorker_DownloadLogsClient interface {
+	Recv() (*DownloadLogsResponse, error)
+	grpc.ClientStream
+}
+
+type teleWorkerDownloadLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *teleWorkerDownloadLogsClient) Recv() (*DownloadLogsResponse, error) {
+	m := new(DownloadLogsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *teleWorkerClient) Attach(ctx context.Context, opts ...grpc.CallOption) (TeleWorker_AttachClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TeleWorker_ServiceDesc.Streams[4], "/v1.TeleWorker/Attach", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &teleWorkerAttachClient{stream}
+	return x, nil
+}
+
+type TeleWorker_AttachClient interface {
+	Send(*AttachRequest) error
+	Recv() (*AttachResponse, error)
+	grpc.ClientStream
+}
+
+type teleWorkerAttachClient struct {
+	grpc.ClientStream
+}
+
+func (x *teleWorkerAttachClient) Send(m *AttachRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *teleWorkerAttachClient) Recv() (*AttachResponse, error) {
+	m := new(AttachResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *teleWorkerClient) Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (TeleWorker_ExecClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TeleWorker_ServiceDesc.Streams[5], "/v1.TeleWorker/Exec", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &teleWorkerExecClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TeleWorker_ExecClient interface {
+	Recv() (*ExecResponse, error)
+	grpc.ClientStream
+}
+
+type teleWorkerExecClient struct {
+	grpc.ClientStream
+}
+
+func (x *teleWorkerExecClient) Recv() (*ExecResponse, error) {
+	m := new(ExecResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *teleWorkerClient) SubmitWorkflow(ctx context.Context, in *SubmitWorkflowRequest, opts ...grpc.CallOption) (*SubmitWorkflowResponse, error) {
+	out := new(SubmitWorkflowResponse)
+	err := c.cc.Invoke(ctx, "/v1.TeleWorker/SubmitWorkflow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teleWorkerClient) WorkflowStatus(ctx context.Context, in *WorkflowStatusRequest, opts ...grpc.CallOption) (*WorkflowStatusResponse, error) {
+	out := new(WorkflowStatusResponse)
+	err := c.cc.Invoke(ctx, "/v1.TeleWorker/WorkflowStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teleWorkerClient) CreateSchedule(ctx context.Context, in *CreateScheduleRequest, opts ...grpc.CallOption) (*CreateScheduleResponse, error) {
+	out := new(CreateScheduleResponse)
+	err := c.cc.Invoke(ctx, "/v1.TeleWorker/CreateSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teleWorkerClient) ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ListSchedulesResponse, error) {
+	out := new(ListSchedulesResponse)
+	err := c.cc.Invoke(ctx, "/v1.TeleWorker/ListSchedules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teleWorkerClient) DeleteSchedule(ctx context.Context, in *DeleteScheduleRequest, opts ...grpc.CallOption) (*DeleteScheduleResponse, error) {
+	out := new(DeleteScheduleResponse)
+	err := c.cc.Invoke(ctx, "/v1.TeleWorker/DeleteSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TeleWorkerServer is the server API for TeleWorker service.
 // All implementations must embed UnimplementedTeleWorkerServer
 // for forward compatibility
 type TeleWorkerServer interface {
 	Start(context.Context, *StartRequest) (*StartResponse, error)
+	StartWithInput(TeleWorker_StartWithInputServer) error
 	Stop(context.Context, *StopRequest) (*StopResponse, error)
 	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
 	Stream(*StreamRequest, TeleWorker_StreamServer) error
+	StreamMany(*StreamManyRequest, TeleWorker_StreamManyServer) error
+	DownloadLogs(*DownloadLogsRequest, TeleWorker_DownloadLogsServer) error
+	Attach(TeleWorker_AttachServer) error
+	Exec(*ExecRequest, TeleWorker_ExecServer) error
+	SubmitWorkflow(context.Context, *SubmitWorkflowRequest) (*SubmitWorkflowResponse, error)
+	WorkflowStatus(context.Context, *WorkflowStatusRequest) (*WorkflowStatusResponse, error)
+	CreateSchedule(context.Context, *CreateScheduleRequest) (*CreateScheduleResponse, error)
+	ListSchedules(context.Context, *ListSchedulesRequest) (*ListSchedulesResponse, error)
+	DeleteSchedule(context.Context, *DeleteScheduleRequest) (*DeleteScheduleResponse, error)
 	mustEmbedUnimplementedTeleWorkerServer()
 }
 
@@ -109,15 +346,48 @@ type UnimplementedTeleWorkerServer struct {
 func (UnimplementedTeleWorkerServer) Start(context.Context, *StartRequest) (*StartResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
 }
+func (UnimplementedTeleWorkerServer) StartWithInput(TeleWorker_StartWithInputServer) error {
+	return status.Errorf(codes.Unimplemented, "method StartWithInput not implemented")
+}
 func (UnimplementedTeleWorkerServer) Stop(context.Context, *StopRequest) (*StopResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
 }
 func (UnimplementedTeleWorkerServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
 }
+func (UnimplementedTeleWorkerServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
 func (UnimplementedTeleWorkerServer) Stream(*StreamRequest, TeleWorker_StreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
 }
+func (UnimplementedTeleWorkerServer) StreamMany(*StreamManyRequest, TeleWorker_StreamManyServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMany not implemented")
+}
+func (UnimplementedTeleWorkerServer) DownloadLogs(*DownloadLogsRequest, TeleWorker_DownloadLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadLogs not implemented")
+}
+func (UnimplementedTeleWorkerServer) Attach(TeleWorker_AttachServer) error {
+	return status.Errorf(codes.Unimplemented, "method Attach not implemented")
+}
+func (UnimplementedTeleWorkerServer) Exec(*ExecRequest, TeleWorker_ExecServer) error {
+	return status.Errorf(codes.Unimplemented, "method Exec not implemented")
+}
+func (UnimplementedTeleWorkerServer) SubmitWorkflow(context.Context, *SubmitWorkflowRequest) (*SubmitWorkflowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitWorkflow not implemented")
+}
+func (UnimplementedTeleWorkerServer) WorkflowStatus(context.Context, *WorkflowStatusRequest) (*WorkflowStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WorkflowStatus not implemented")
+}
+func (UnimplementedTeleWorkerServer) CreateSchedule(context.Context, *CreateScheduleRequest) (*CreateScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSchedule not implemented")
+}
+func (UnimplementedTeleWorkerServer) ListSchedules(context.Context, *ListSchedulesRequest) (*ListSchedulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSchedules not implemented")
+}
+func (UnimplementedTeleWorkerServer) DeleteSchedule(context.Context, *DeleteScheduleRequest) (*DeleteScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSchedule not implemented")
+}
 func (UnimplementedTeleWorkerServer) mustEmbedUnimplementedTeleWorkerServer() {}
 
 // UnsafeTeleWorkerServer may be embedded to opt out of forward compatibility for this service.
@@ -149,6 +419,32 @@ func _TeleWorker_Start_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TeleWorker_StartWithInput_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TeleWorkerServer).StartWithInput(&teleWorkerStartWithInputServer{stream})
+}
+
+type TeleWorker_StartWithInputServer interface {
+	SendAndClose(*StartResponse) error
+	Recv() (*StartWithInputRequest, error)
+	grpc.ServerStream
+}
+
+type teleWorkerStartWithInputServer struct {
+	grpc.ServerStream
+}
+
+func (x *teleWorkerStartWithInputServer) SendAndClose(m *StartResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *teleWorkerStartWithInputServer) Recv() (*StartWithInputRequest, error) {
+	m := new(StartWithInputRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _TeleWorker_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(StopRequest)
 	if err := dec(in); err != nil {
@@ -185,6 +481,24 @@ func _TeleWorker_Status_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TeleWorker_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeleWorkerServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.TeleWorker/ListJobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeleWorkerServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TeleWorker_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(StreamRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -206,6 +520,185 @@ func (x *teleWorkerStreamServer) Send(m *StreamResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _TeleWorker_StreamMany_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamManyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TeleWorkerServer).StreamMany(m, &teleWorkerStreamManyServer{stream})
+}
+
+type TeleWorker_StreamManyServer interface {
+	Send(*StreamManyResponse) error
+	grpc.ServerStream
+}
+
+type teleWorkerStreamManyServer struct {
+	grpc.ServerStream
+}
+
+func (x *teleWorkerStreamManyServer) Send(m *StreamManyResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TeleWorker_DownloadLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TeleWorkerServer).DownloadLogs(m, &teleWorkerDownloadLogsServer{stream})
+}
+
+type TeleWorker_DownloadLogsServer interface {
+	Send(*DownloadLogsResponse) error
+	grpc.ServerStream
+}
+
+type teleWorkerDownloadLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *teleWorkerDownloadLogsServer) Send(m *DownloadLogsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TeleWorker_Attach_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TeleWorkerServer).Attach(&teleWorkerAttachServer{stream})
+}
+
+type TeleWorker_AttachServer interface {
+	Send(*AttachResponse) error
+	Recv() (*AttachRequest, error)
+	grpc.ServerStream
+}
+
+type teleWorkerAttachServer struct {
+	grpc.ServerStream
+}
+
+func (x *teleWorkerAttachServer) Send(m *AttachResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *teleWorkerAttachServer) Recv() (*AttachRequest, error) {
+	m := new(AttachRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _TeleWorker_Exec_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TeleWorkerServer).Exec(m, &teleWorkerExecServer{stream})
+}
+
+type TeleWorker_ExecServer interface {
+	Send(*ExecResponse) error
+	grpc.ServerStream
+}
+
+type teleWorkerExecServer struct {
+	grpc.ServerStream
+}
+
+func (x *teleWorkerExecServer) Send(m *ExecResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TeleWorker_SubmitWorkflow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitWorkflowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeleWorkerServer).SubmitWorkflow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.TeleWorker/SubmitWorkflow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeleWorkerServer).SubmitWorkflow(ctx, req.(*SubmitWorkflowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TeleWorker_WorkflowStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkflowStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeleWorkerServer).WorkflowStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.TeleWorker/WorkflowStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeleWorkerServer).WorkflowStatus(ctx, req.(*WorkflowStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TeleWorker_CreateSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeleWorkerServer).CreateSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.TeleWorker/CreateSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeleWorkerServer).CreateSchedule(ctx, req.(*CreateScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TeleWorker_ListSchedules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSchedulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeleWorkerServer).ListSchedules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.TeleWorker/ListSchedules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeleWorkerServer).ListSchedules(ctx, req.(*ListSchedulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TeleWorker_DeleteSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeleWorkerServer).DeleteSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.TeleWorker/DeleteSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeleWorkerServer).DeleteSchedule(ctx, req.(*DeleteScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TeleWorker_ServiceDesc is the grpc.ServiceDesc for TeleWorker service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -225,13 +718,63 @@ var TeleWorker_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Status",
 			Handler:    _TeleWorker_Status_Handler,
 		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _TeleWorker_ListJobs_Handler,
+		},
+		{
+			MethodName: "SubmitWorkflow",
+			Handler:    _TeleWorker_SubmitWorkflow_Handler,
+		},
+		{
+			MethodName: "WorkflowStatus",
+			Handler:    _TeleWorker_WorkflowStatus_Handler,
+		},
+		{
+			MethodName: "CreateSchedule",
+			Handler:    _TeleWorker_CreateSchedule_Handler,
+		},
+		{
+			MethodName: "ListSchedules",
+			Handler:    _TeleWorker_ListSchedules_Handler,
+		},
+		{
+			MethodName: "DeleteSchedule",
+			Handler:    _TeleWorker_DeleteSchedule_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StartWithInput",
+			Handler:       _TeleWorker_StartWithInput_Handler,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "Stream",
 			Handler:       _TeleWorker_Stream_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StreamMany",
+			Handler:       _TeleWorker_StreamMany_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "DownloadLogs",
+			Handler:       _TeleWorker_DownloadLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Attach",
+			Handler:       _TeleWorker_Attach_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Exec",
+			Handler:       _TeleWorker_Exec_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "v1/teleworker.proto",
 }
@@ -0,0 +1,124 @@
+package logstreamer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+var subscriberCounts = []int{1, 10, 100}
+
+// subscribe starts n streams following the output,
+// every received chunk is reported to the callback
+func subscribe(s *LogStreamer, n int, received func(Chunk)) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		ch := s.Follow(context.Background())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range ch {
+				received(chunk)
+			}
+		}()
+	}
+	return &wg
+}
+
+// BenchmarkStreamThroughput measures how fast the output
+// is delivered to all the streams following it
+func BenchmarkStreamThroughput(b *testing.B) {
+	for _, subscribers := range subscriberCounts {
+		b.Run(fmt.Sprintf("subscribers=%d", subscribers), func(b *testing.B) {
+			reader, writer := io.Pipe()
+			s := NewLogStreamer(reader)
+			wg := subscribe(s, subscribers, func(Chunk) {})
+
+			pack := make([]byte, defaultBufSize)
+			b.SetBytes(int64(len(pack)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				writer.Write(pack)
+			}
+			writer.Close()
+			wg.Wait()
+		})
+	}
+}
+
+// BenchmarkStreamLatency measures the time between writing
+// the output and receiving it by all the following streams
+func BenchmarkStreamLatency(b *testing.B) {
+	for _, subscribers := range subscriberCounts {
+		b.Run(fmt.Sprintf("subscribers=%d", subscribers), func(b *testing.B) {
+			reader, writer := io.Pipe()
+			s := NewLogStreamer(reader)
+
+			var delivered sync.WaitGroup
+			wg := subscribe(s, subscribers, func(Chunk) {
+				delivered.Done()
+			})
+
+			pack := []byte("x")
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				delivered.Add(subscribers)
+				writer.Write(pack)
+				delivered.Wait()
+			}
+
+			b.StopTimer()
+			writer.Close()
+			wg.Wait()
+		})
+	}
+}
+
+// BenchmarkMemoryPerJob measures the memory taken by the output of
+// a single job, which is 4 MiB of the typical text log lines
+func BenchmarkMemoryPerJob(b *testing.B) {
+	output := textOutput(4 * 1024 * 1024)
+
+	buffers := []struct {
+		name string
+		new  func(*Budget) buffer
+	}{
+		{name: "plain", new: func(budget *Budget) buffer { return NewRingBuf(0, budget) }},
+		{name: "compressed", new: func(budget *Budget) buffer { return NewCompressedBuf(0, budget) }},
+	}
+	for _, bb := range buffers {
+		b.Run(bb.name, func(b *testing.B) {
+			b.SetBytes(int64(len(output)))
+
+			var used int64
+			for i := 0; i < b.N; i++ {
+				budget := NewBudget(1 << 40)
+				buf := bb.new(budget)
+				writeInPieces(buf, output)
+				used = budget.Used()
+				buf.Release()
+			}
+
+			b.ReportMetric(float64(used), "mem-bytes/job")
+			b.ReportMetric(float64(len(output)-int(used))/float64(len(output))*100, "%saved")
+		})
+	}
+}
+
+// BenchmarkCompressedReplay measures how fast the compressed output is read
+func BenchmarkCompressedReplay(b *testing.B) {
+	output := textOutput(4 * 1024 * 1024)
+	buf := NewCompressedBuf(0, nil)
+	writeInPieces(buf, output)
+
+	b.SetBytes(int64(len(output)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		io.Copy(io.Discard, io.NewSectionReader(buf.Reader(), 0, int64(buf.Len())))
+	}
+}
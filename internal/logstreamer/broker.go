@@ -1,27 +1,173 @@
 package logstreamer
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+)
 
+// SlowSubscriberPolicy defines what happens to the subscriber
+// which doesn't keep up with the messages, i.e. its queue is full
+type SlowSubscriberPolicy int
+
+const (
+	// DropOldest drops the oldest messages from the queue, the
+	// subscriber is told about the number of the dropped bytes
+	DropOldest SlowSubscriberPolicy = iota
+	// Disconnect closes the subscription with ErrSlowSubscriber
+	Disconnect
+)
+
+// ErrSlowSubscriber is the reason of closing the subscription, which
+// doesn't keep up with the messages under the Disconnect policy
+var ErrSlowSubscriber = errors.New("the subscriber is too slow to keep up with the output")
+
+var (
+	droppedBytes     = expvar.NewInt("logstreamer_dropped_bytes")
+	disconnectedSubs = expvar.NewInt("logstreamer_disconnected_subscribers")
+)
+
+// BrokerSub is the queue of the messages delivered to the subscriber
 type BrokerSub struct {
-	load chan []byte
+	mu     sync.Mutex
+	queue  [][]byte
+	size   int
+	limit  int
+	policy SlowSubscriberPolicy
+	// dropped is the number of bytes dropped right before the first
+	// queued message, err is the reason the subscription was closed for
+	dropped int64
+	err     error
+
+	// complete is closed when there are no more messages to come
+	complete chan struct{}
+	closed   bool
+	// updated receives a signal when some message is
+	// queued, pending signals are not accumulated
+	updated chan struct{}
+}
+
+// push adds the message to the queue without ever blocking.
+// Returns false if the subscription is closed due to it
+func (s *BrokerSub) push(msg []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limit > 0 && s.size+len(msg) > s.limit {
+		if s.policy == Disconnect {
+			disconnectedSubs.Add(1)
+			s.closeLocked(ErrSlowSubscriber)
+			return false
+		}
+
+		for len(s.queue) > 0 && s.size+len(msg) > s.limit {
+			oldest := s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			s.size -= len(oldest)
+			s.dropped += int64(len(oldest))
+			droppedBytes.Add(int64(len(oldest)))
+		}
+	}
+
+	s.queue = append(s.queue, msg)
+	s.size += len(msg)
+	select {
+	case s.updated <- struct{}{}:
+	default:
+		// The subscriber is notified already
+	}
+	return true
+}
+
+// close makes the subscription complete, the
+// queued messages are still available though
+func (s *BrokerSub) close(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked(err)
+}
+
+func (s *BrokerSub) closeLocked(err error) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.complete)
+}
+
+// Next takes the oldest message from the queue along with the number of
+// bytes dropped right before it. The message is nil if the queue is empty
+func (s *BrokerSub) Next() ([]byte, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dropped := s.dropped
+	s.dropped = 0
+	if len(s.queue) == 0 {
+		return nil, dropped
+	}
+
+	msg := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	s.size -= len(msg)
+	return msg, dropped
+}
+
+// Wait blocks until some message is queued or the subscription
+// is closed since the last call, or the context is done
+func (s *BrokerSub) Wait(ctx context.Context) error {
+	select {
+	case <-s.updated:
+		return nil
+	case <-s.complete:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Complete returns whether no more messages are going to be queued
+func (s *BrokerSub) Complete() bool {
+	select {
+	case <-s.complete:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns the reason the subscription was closed for, if it
+// was closed due to the subscriber and not the end of the messages
+func (s *BrokerSub) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
 }
 
 // Broker is message broker consuming messages from one
 // source and delivering them to multiple subscribers
 type Broker struct {
-	mu   sync.Mutex
-	subs map[*BrokerSub]struct{}
+	mu      sync.Mutex
+	subs    map[*BrokerSub]struct{}
+	stopped bool
 
-	listener chan []byte
-	quit     chan struct{}
+	// queueLimit is the number of bytes every subscriber queue is
+	// limited to, policy defines what happens once it is reached
+	queueLimit int
+	policy     SlowSubscriberPolicy
 }
 
-func NewBroker() *Broker {
+// NewBroker creates the broker with the subscriber queues limited
+// to queueLimit bytes, zero meaning the queues are unlimited
+func NewBroker(queueLimit int, policy SlowSubscriberPolicy) *Broker {
 	return &Broker{
-		subs: make(map[*BrokerSub]struct{}),
-
-		listener: make(chan []byte, 1),
-		quit:     make(chan struct{}),
+		subs:       make(map[*BrokerSub]struct{}),
+		queueLimit: queueLimit,
+		policy:     policy,
 	}
 }
 
@@ -30,7 +176,15 @@ func (b *Broker) Subscribe() *BrokerSub {
 	defer b.mu.Unlock()
 
 	sub := &BrokerSub{
-		load: make(chan []byte),
+		limit:    b.queueLimit,
+		policy:   b.policy,
+		complete: make(chan struct{}),
+		updated:  make(chan struct{}, 1),
+	}
+	if b.stopped {
+		// Nothing is going to be broadcasted anymore
+		sub.close(nil)
+		return sub
 	}
 	b.subs[sub] = struct{}{}
 
@@ -43,39 +197,36 @@ func (b *Broker) Unsubscribe(s *BrokerSub) {
 
 	if _, ok := b.subs[s]; !ok {
 		// Check this as at this point we might already stop
-		// broadcasting and the channel is already closed
+		// broadcasting and the subscription is already closed
 		return
 	}
-	close(s.load)
+	s.close(nil)
 	delete(b.subs, s)
 }
 
+// Send delivers the message to all the current subscribers. The message
+// is queued by the time the call returns, so the subscribers added
+// afterwards are guaranteed not to receive it. The call never blocks
+// on the subscribers, the slow ones are handled according to the policy
 func (b *Broker) Send(msg []byte) {
-	b.listener <- msg
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if !sub.push(msg) {
+			delete(b.subs, sub)
+		}
+	}
 }
 
+// Stop closes all the subscriptions, nothing can be sent afterwards
 func (b *Broker) Stop() {
-	close(b.quit)
-}
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-func (b *Broker) Broadcast() {
-	for {
-		select {
-		case msg := <-b.listener:
-			// Resending the published message to all the subs
-			b.mu.Lock()
-			for sub := range b.subs {
-				sub.load <- msg
-			}
-			b.mu.Unlock()
-		case <-b.quit:
-			b.mu.Lock()
-			for sub := range b.subs {
-				close(sub.load)
-				delete(b.subs, sub)
-			}
-			b.mu.Unlock()
-			return
-		}
+	for sub := range b.subs {
+		sub.close(nil)
+		delete(b.subs, sub)
 	}
+	b.stopped = true
 }
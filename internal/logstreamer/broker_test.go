@@ -0,0 +1,80 @@
+package logstreamer
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerDropsOldestMessages(t *testing.T) {
+	b := NewBroker(4, DropOldest)
+	sub := b.Subscribe()
+
+	dropped := droppedBytes.Value()
+	for _, msg := range []string{"ab", "cd", "ef"} {
+		b.Send([]byte(msg))
+	}
+	assert.Equal(t, dropped+2, droppedBytes.Value())
+
+	msg, gap := sub.Next()
+	assert.Equal(t, "cd", string(msg))
+	assert.Equal(t, int64(2), gap)
+
+	msg, gap = sub.Next()
+	assert.Equal(t, "ef", string(msg))
+	assert.Equal(t, int64(0), gap)
+
+	msg, _ = sub.Next()
+	assert.Nil(t, msg)
+	assert.False(t, sub.Complete())
+}
+
+func TestBrokerDisconnectsSlowSubscriber(t *testing.T) {
+	b := NewBroker(4, Disconnect)
+	slow := b.Subscribe()
+	fast := b.Subscribe()
+
+	for _, msg := range []string{"ab", "cd", "ef"} {
+		b.Send([]byte(msg))
+		if msg == "ab" {
+			fast.Next()
+		}
+	}
+
+	assert.True(t, slow.Complete())
+	assert.ErrorIs(t, slow.Err(), ErrSlowSubscriber)
+	assert.False(t, fast.Complete())
+
+	// The messages queued before are still available
+	msg, _ := slow.Next()
+	assert.Equal(t, "ab", string(msg))
+}
+
+func TestStreamReportsDroppedLiveOutput(t *testing.T) {
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader, WithSubscriberQueue(4, DropOldest))
+
+	// Nobody reads the stream while the output is written
+	ch := s.Follow(context.Background())
+	for _, data := range []string{"ab", "cd", "ef", "gh"} {
+		written := s.Written()
+		writer.Write([]byte(data))
+		for s.Written() == written {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	writer.Close()
+
+	var next, truncated int64
+	for chunk := range ch {
+		next += chunk.Truncated
+		truncated += chunk.Truncated
+		assert.Equal(t, next, chunk.Offset)
+		next += int64(len(chunk.Data))
+	}
+	assert.Equal(t, int64(8), next)
+	assert.Greater(t, truncated, int64(0))
+}
@@ -0,0 +1,67 @@
+package logstreamer
+
+import "sync"
+
+// Budget limits the total amount of output kept in memory by all the
+// buffers sharing it. Once the budget is exhausted the buffers stop
+// growing and keep only the latest data they already have space for.
+// Nil budget is valid and means that there are no limits
+type Budget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+}
+
+func NewBudget(limit int64) *Budget {
+	return &Budget{
+		limit: limit,
+	}
+}
+
+// Used returns the number of bytes currently taken from the budget
+func (b *Budget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// reserve takes n bytes from the budget if there is enough space left
+func (b *Budget) reserve(n int) bool {
+	if b == nil || n == 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.used+int64(n) > b.limit {
+		return false
+	}
+	b.used += int64(n)
+	return true
+}
+
+// force takes n bytes from the budget even if it is exhausted
+func (b *Budget) force(n int) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used += int64(n)
+}
+
+func (b *Budget) release(n int) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= int64(n)
+}
@@ -0,0 +1,283 @@
+package logstreamer
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// segmentSize is the amount of the output compressed as a single segment,
+// reading at any offset requires decompressing the segment containing it
+const segmentSize = 64 * 1024
+
+// flateWriters are shared by all the buffers, as every writer
+// takes much more memory than a compressed segment does
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+// segment is the compressed piece of the output
+type segment struct {
+	// start is the position of the segment counting
+	// from the first byte ever written to the buffer
+	start int64
+	size  int
+	data  []byte
+}
+
+func (s *segment) end() int64 {
+	return s.start + int64(s.size)
+}
+
+// decode returns the decompressed segment data
+func (s *segment) decode() ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(s.data))
+	defer r.Close()
+
+	data := make([]byte, s.size)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing the output segment: %w", err)
+	}
+	return data, nil
+}
+
+// CompressedBuf keeps the last bytes written to it similarly to RingBuf,
+// but compressed in segments, so the same memory holds much more of
+// the text output. The limit and the budget apply to the memory taken,
+// the oldest segments are dropped once it is exceeded. The latest output
+// is kept as it is until there is enough of it to fill the segment.
+// CompressedBuf is not safe for concurrent use
+type CompressedBuf struct {
+	segments []*segment
+	// tail is the output written after the last segment
+	tail []byte
+	// written is the total number of bytes written to the buffer
+	written int64
+	// limit is the maximum amount of memory taken, 0 means unlimited,
+	// used is the amount taken by the segments and the tail
+	limit int
+	used  int
+
+	budget *Budget
+	// truncated is the number of bytes dropped since the beginning
+	truncated int64
+}
+
+func NewCompressedBuf(limit int, budget *Budget) *CompressedBuf {
+	return &CompressedBuf{
+		limit:  limit,
+		budget: budget,
+	}
+}
+
+func (b *CompressedBuf) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		k := segmentSize - len(b.tail)
+		if k > len(p) {
+			k = len(p)
+		}
+		b.reserve(k)
+		b.tail = append(b.tail, p[:k]...)
+		b.written += int64(k)
+		p = p[k:]
+
+		if len(b.tail) == segmentSize {
+			err := b.seal()
+			if err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	b.evict()
+
+	return n, nil
+}
+
+// reserve accounts the memory taken by n more bytes of the tail
+func (b *CompressedBuf) reserve(n int) {
+	if !b.budget.reserve(n) {
+		// The budget is exhausted, so the buffer stops growing
+		// and starts dropping its oldest data instead
+		b.shrinkLimit()
+		b.budget.force(n)
+	}
+	b.used += n
+}
+
+// shrinkLimit limits the buffer by the memory it currently takes,
+// as it is not allowed to grow anymore, keeping at least the
+// minimal retained amount
+func (b *CompressedBuf) shrinkLimit() {
+	limit := b.used
+	if limit < minRetained {
+		limit = minRetained
+	}
+	if b.limit == 0 || limit < b.limit {
+		b.limit = limit
+	}
+}
+
+// seal compresses the tail into the new segment
+func (b *CompressedBuf) seal() error {
+	var data bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&data)
+
+	_, err := w.Write(b.tail)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error compressing the output segment: %w", err)
+	}
+
+	s := &segment{
+		start: b.written - int64(len(b.tail)),
+		size:  len(b.tail),
+		// The buffer is usually larger than the compressed data
+		data: append([]byte(nil), data.Bytes()...),
+	}
+	b.segments = append(b.segments, s)
+
+	b.budget.release(len(b.tail))
+	b.budget.force(len(s.data))
+	b.used += len(s.data) - len(b.tail)
+	b.tail = b.tail[:0]
+	return nil
+}
+
+// evict drops the oldest output until the buffer fits the limit
+func (b *CompressedBuf) evict() {
+	if b.limit == 0 {
+		return
+	}
+
+	for b.used > b.limit && len(b.segments) > 0 {
+		s := b.segments[0]
+		b.segments[0] = nil
+		b.segments = b.segments[1:]
+
+		b.used -= len(s.data)
+		b.budget.release(len(s.data))
+		b.truncated += int64(s.size)
+	}
+
+	if b.used > b.limit {
+		// Even the latest output alone doesn't fit
+		drop := b.used - b.limit
+		b.tail = append(b.tail[:0], b.tail[drop:]...)
+		b.used -= drop
+		b.budget.release(drop)
+		b.truncated += int64(drop)
+	}
+}
+
+// Len returns the number of bytes kept in the buffer
+func (b *CompressedBuf) Len() int {
+	return int(b.written - b.truncated)
+}
+
+// Memory returns the amount of memory taken by the kept bytes
+func (b *CompressedBuf) Memory() int {
+	return b.used
+}
+
+// Truncated returns the number of bytes dropped since the beginning
+func (b *CompressedBuf) Truncated() int64 {
+	return b.truncated
+}
+
+// Reader returns the reader of the data kept in the buffer at the moment of
+// the call, the oldest byte being at the offset 0. Only the tail is copied,
+// the segments are never changed, so they are shared with the buffer
+func (b *CompressedBuf) Reader() io.ReaderAt {
+	return &segmentReader{
+		segments: append([]*segment(nil), b.segments...),
+		tail:     append([]byte(nil), b.tail...),
+		base:     b.truncated,
+		end:      b.written,
+	}
+}
+
+// Release frees the buffer memory and returns it to the budget
+func (b *CompressedBuf) Release() {
+	b.budget.release(b.used)
+	b.segments = nil
+	b.tail = nil
+	b.used = 0
+}
+
+// segmentReader reads the data of the buffer, decompressing
+// the segments on demand. The last decompressed segment is
+// kept, as the data is mostly read sequentially
+type segmentReader struct {
+	segments []*segment
+	tail     []byte
+	// base and end are the positions of the first and the
+	// last bytes counting from the beginning of the buffer
+	base int64
+	end  int64
+
+	mu      sync.Mutex
+	current *segment
+	decoded []byte
+}
+
+func (r *segmentReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	pos := r.base + off
+	n := 0
+	for n < len(p) && pos < r.end {
+		data, start, err := r.piece(pos)
+		if err != nil {
+			return n, err
+		}
+		k := copy(p[n:], data[pos-start:])
+		n += k
+		pos += int64(k)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// piece returns the data containing the position along with the position
+// it starts at, which is either one of the segments or the tail
+func (r *segmentReader) piece(pos int64) ([]byte, int64, error) {
+	tailStart := r.end - int64(len(r.tail))
+	if pos >= tailStart {
+		return r.tail, tailStart, nil
+	}
+
+	i := sort.Search(len(r.segments), func(i int) bool {
+		return r.segments[i].end() > pos
+	})
+	s := r.segments[i]
+	if s != r.current {
+		data, err := s.decode()
+		if err != nil {
+			return nil, 0, err
+		}
+		r.current, r.decoded = s, data
+	}
+
+	return r.decoded, s.start, nil
+}
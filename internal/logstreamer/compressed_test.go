@@ -0,0 +1,131 @@
+package logstreamer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// textOutput generates the typical log output of the given size
+func textOutput(size int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	methods := []string{"GET", "POST", "PUT", "DELETE"}
+	levels := []string{"INFO", "INFO", "INFO", "WARN", "ERROR"}
+
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "2021-11-02T09:%02d:%02d.%06dZ %s request handled method=%s path=/api/v1/jobs/%d status=%d latency_ms=%d\n",
+			i/3600%60, i/60%60, rnd.Intn(1000000), levels[rnd.Intn(len(levels))],
+			methods[rnd.Intn(len(methods))], rnd.Intn(100000), 200+rnd.Intn(4)*100, rnd.Intn(2000))
+	}
+	return buf.Bytes()[:size]
+}
+
+// writeInPieces writes the data to the writer in the pieces of random sizes
+func writeInPieces(w io.Writer, data []byte) {
+	rnd := rand.New(rand.NewSource(2))
+	for len(data) > 0 {
+		n := 1 + rnd.Intn(3*defaultBufSize)
+		if n > len(data) {
+			n = len(data)
+		}
+		w.Write(data[:n])
+		data = data[n:]
+	}
+}
+
+func TestCompressedBufReadsAtAnyOffset(t *testing.T) {
+	output := textOutput(5*segmentSize + 1234)
+	b := NewCompressedBuf(0, nil)
+	writeInPieces(b, output)
+
+	assert.Equal(t, len(output), b.Len())
+	assert.Less(t, b.Memory(), len(output)/3)
+
+	r := b.Reader()
+	all, err := ioutil.ReadAll(io.NewSectionReader(r, 0, int64(b.Len())))
+	assert.NoError(t, err)
+	assert.Equal(t, output, all)
+
+	// Reading across the segments and the tail
+	for _, off := range []int64{0, segmentSize - 10, 3*segmentSize + 7, int64(len(output)) - 20} {
+		p := make([]byte, 100)
+		n, err := r.ReadAt(p, off)
+		expected := output[off:]
+		if len(expected) > len(p) {
+			expected = expected[:len(p)]
+			assert.NoError(t, err)
+		} else {
+			assert.Equal(t, io.EOF, err)
+		}
+		assert.Equal(t, expected, p[:n], "offset %d", off)
+	}
+}
+
+func TestCompressedBufKeepsLatestBytes(t *testing.T) {
+	const limit = 4 * segmentSize
+	output := textOutput(20 * segmentSize)
+	b := NewCompressedBuf(limit, nil)
+	writeInPieces(b, output)
+
+	assert.LessOrEqual(t, b.Memory(), limit)
+	assert.Equal(t, int64(len(output)), b.Truncated()+int64(b.Len()))
+	// Much more than the limit is kept
+	assert.Greater(t, b.Len(), 2*limit)
+
+	kept := make([]byte, b.Len())
+	_, err := b.Reader().ReadAt(kept, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, output[b.Truncated():], kept)
+}
+
+func TestCompressedBufDropsTheTailBeyondLimit(t *testing.T) {
+	b := NewCompressedBuf(4, nil)
+	b.Write([]byte("ab"))
+	b.Write([]byte("cdefghij"))
+
+	kept := make([]byte, b.Len())
+	b.Reader().ReadAt(kept, 0)
+	assert.Equal(t, "ghij", string(kept))
+	assert.Equal(t, int64(6), b.Truncated())
+}
+
+func TestCompressedBufRespectsBudget(t *testing.T) {
+	budget := NewBudget(minRetained)
+
+	first := NewCompressedBuf(0, budget)
+	first.Write(make([]byte, minRetained/2))
+	second := NewCompressedBuf(0, budget)
+	second.Write(textOutput(8 * segmentSize))
+	assert.LessOrEqual(t, second.Memory(), minRetained)
+
+	first.Release()
+	second.Release()
+	assert.Equal(t, int64(0), budget.Used())
+}
+
+func TestStreamCompressedOutput(t *testing.T) {
+	output := textOutput(3*segmentSize + 100)
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader, WithCompression())
+
+	go func() {
+		writeInPieces(writer, output)
+		writer.Close()
+	}()
+	// Wait for the output to be over, so everything is replayed
+	for range s.Stream(context.Background(), StreamOptions{}) {
+	}
+
+	var streamed []byte
+	for chunk := range s.Stream(context.Background(), StreamOptions{From: segmentSize + 5}) {
+		streamed = append(streamed, chunk.Data...)
+	}
+	assert.Equal(t, output[segmentSize+5:], streamed)
+}
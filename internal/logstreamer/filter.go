@@ -0,0 +1,205 @@
+package logstreamer
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+)
+
+// maxLineSize limits the lines kept by the filter while waiting for
+// their end, the longer ones are split as if the line break was there
+const maxLineSize = 64 * 1024
+
+// LineFilter selects the lines of the output similarly to grep
+type LineFilter struct {
+	// Include keeps only the lines matching it, if set
+	Include *regexp.Regexp
+	// Exclude drops the lines matching it, if set
+	Exclude *regexp.Regexp
+	// Where keeps only the JSON lines matching all the predicates
+	Where []Predicate
+	// Fields replaces the JSON lines with the objects
+	// containing only the listed fields, if set
+	Fields []string
+	// Context is the number of lines around the
+	// selected ones which are kept as well
+	Context int
+}
+
+func (f *LineFilter) match(line []byte) bool {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if f.Include != nil && !f.Include.Match(line) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.Match(line) {
+		return false
+	}
+	if len(f.Where) == 0 {
+		return true
+	}
+
+	obj, ok := parseObject(line)
+	if !ok {
+		return false
+	}
+	for _, p := range f.Where {
+		if !p.match(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+// render returns the line as it is to be sent
+func (f *LineFilter) render(line Chunk) Chunk {
+	if len(f.Fields) == 0 {
+		return line
+	}
+
+	obj, ok := parseObject(line.Data)
+	if !ok {
+		// Not a JSON line, e.g. one of the context lines
+		return line
+	}
+	line.Size = int64(len(line.Data))
+	line.Data = selectFields(obj, f.Fields)
+	return line
+}
+
+// lineState is the progress of filtering the output of a single source
+type lineState struct {
+	// line is the beginning of the line which end is not yet received
+	line Chunk
+	// before contains the latest lines which are not selected,
+	// after is the number of lines to keep after the selected one
+	before []Chunk
+	after  int
+}
+
+// FilterLines streams only the lines of the stream selected by the filter,
+// every chunk containing a single complete line, possibly rendered with
+// the selected fields only. The line carries the offset, the sequence
+// number and the time of its beginning. The lines of the merged streams
+// are assembled for every source separately. The incomplete line is
+// held until its end comes or the stream is over
+func FilterLines(ctx context.Context, in <-chan Chunk, f LineFilter) <-chan Chunk {
+	ch := make(chan Chunk)
+
+	go func() {
+		defer close(ch)
+
+		send := func(chunk Chunk) bool {
+			if chunk.Truncated == 0 && chunk.Err == nil {
+				chunk = f.render(chunk)
+			}
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// process decides whether the complete line is to be sent
+		process := func(state *lineState, line Chunk) bool {
+			if f.match(line.Data) {
+				for _, before := range state.before {
+					if !send(before) {
+						return false
+					}
+				}
+				state.before = state.before[:0]
+				state.after = f.Context
+				return send(line)
+			}
+
+			if state.after > 0 {
+				state.after--
+				return send(line)
+			}
+			if f.Context > 0 {
+				if len(state.before) == f.Context {
+					state.before = append(state.before[:0], state.before[1:]...)
+				}
+				state.before = append(state.before, line)
+			}
+			return true
+		}
+
+		// flush processes the incomplete line as it is
+		flush := func(state *lineState) bool {
+			line := state.line
+			state.line = Chunk{}
+			if len(line.Data) == 0 {
+				return true
+			}
+			return process(state, line)
+		}
+
+		states := make(map[int]*lineState)
+		for {
+			var chunk Chunk
+			var ok bool
+			select {
+			case chunk, ok = <-in:
+			case <-ctx.Done():
+				return
+			}
+			if !ok {
+				break
+			}
+
+			state, found := states[chunk.Source]
+			if !found {
+				state = &lineState{}
+				states[chunk.Source] = state
+			}
+
+			if chunk.Truncated > 0 || chunk.Err != nil {
+				// The line before the gap is not going to be complete
+				if !flush(state) || !send(chunk) {
+					return
+				}
+				continue
+			}
+
+			data := chunk.Data
+			offset := chunk.Offset
+			for len(data) > 0 {
+				if len(state.line.Data) == 0 {
+					state.line = Chunk{
+						Offset: offset,
+						Seq:    chunk.Seq,
+						Source: chunk.Source,
+						Time:   chunk.Time,
+					}
+				}
+
+				n := bytes.IndexByte(data, '\n') + 1
+				complete := n > 0
+				if !complete {
+					n = len(data)
+				}
+				// The data of the chunk might be reused, so it is copied
+				state.line.Data = append(state.line.Data, data[:n]...)
+				data = data[n:]
+				offset += int64(n)
+
+				if complete || len(state.line.Data) >= maxLineSize {
+					if !flush(state) {
+						return
+					}
+				}
+			}
+		}
+
+		// The output is over, so the incomplete lines are complete
+		for _, state := range states {
+			if !flush(state) {
+				return
+			}
+		}
+	}()
+
+	return ch
+}
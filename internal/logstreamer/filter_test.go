@@ -0,0 +1,74 @@
+package logstreamer
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterLines(t *testing.T) {
+	const output = "start\nerror: one\nok\nok\nok\nwarn: two\nerror: three\nend"
+
+	tests := []struct {
+		name     string
+		filter   LineFilter
+		expected []string
+	}{
+		{
+			name:     "include",
+			filter:   LineFilter{Include: regexp.MustCompile("^error")},
+			expected: []string{"error: one\n", "error: three\n"},
+		},
+		{
+			name:     "exclude",
+			filter:   LineFilter{Exclude: regexp.MustCompile("ok")},
+			expected: []string{"start\n", "error: one\n", "warn: two\n", "error: three\n", "end"},
+		},
+		{
+			name: "include and exclude",
+			filter: LineFilter{
+				Include: regexp.MustCompile(": "),
+				Exclude: regexp.MustCompile("two"),
+			},
+			expected: []string{"error: one\n", "error: three\n"},
+		},
+		{
+			name: "context",
+			filter: LineFilter{
+				Include: regexp.MustCompile("^error"),
+				Context: 1,
+			},
+			expected: []string{"start\n", "error: one\n", "ok\n", "warn: two\n", "error: three\n", "end"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every split of the output into chunks gives the same lines
+			for size := 1; size <= len(output); size++ {
+				in := make(chan Chunk)
+				go func() {
+					defer close(in)
+					for offset := 0; offset < len(output); offset += size {
+						end := offset + size
+						if end > len(output) {
+							end = len(output)
+						}
+						in <- Chunk{Offset: int64(offset), Data: []byte(output[offset:end])}
+					}
+				}()
+
+				var lines []string
+				for chunk := range FilterLines(context.Background(), in, tt.filter) {
+					// Every line starts at its own offset
+					assert.Equal(t, int64(strings.Index(output, string(chunk.Data))), chunk.Offset)
+					lines = append(lines, string(chunk.Data))
+				}
+				assert.Equal(t, tt.expected, lines, "chunk size %d", size)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package logstreamer
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sequence numbers the pieces of the output in the order they were
+// produced. Sharing it between the log streamers makes their
+// outputs comparable, e.g. for merging stdout and stderr
+type Sequence struct {
+	last uint64
+}
+
+func NewSequence() *Sequence {
+	return &Sequence{}
+}
+
+// next returns the next number of the sequence, starting from 1
+func (s *Sequence) next() uint64 {
+	return atomic.AddUint64(&s.last, 1)
+}
+
+// markSpacing is the least distance between the marks kept for the output
+// which is only available in the sink, see index.thin
+const markSpacing = 64 * 1024
+
+// mark is the position in the output where some published piece starts
+type mark struct {
+	offset int64
+	seq    uint64
+	// time is the moment the piece was read in unix nanoseconds
+	time int64
+	// merged is set if the pieces following this one were
+	// thinned out, so the piece spans all of them
+	merged bool
+}
+
+// index keeps the boundaries of the published pieces of the output,
+// so the streams are able to split it into the same pieces again.
+// The marks of the output kept in memory are all in dense, while the
+// older ones are either dropped or thinned out into sparse
+type index struct {
+	mu     sync.Mutex
+	sparse []mark
+	dense  []mark
+}
+
+func (i *index) add(offset int64, seq uint64, t time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.dense = append(i.dense, mark{offset: offset, seq: seq, time: t.UnixNano()})
+}
+
+// trim drops the marks of the pieces ending before the base offset
+func (i *index) trim(base int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.dense = i.dense[i.ended(base):]
+}
+
+// thin keeps the marks of the pieces ending before the base offset only
+// every markSpacing bytes, so the index of the output kept in the sink
+// grows much slower than the output itself. Such output is split into
+// the coarser pieces then, carrying the number and time of the first one
+func (i *index) thin(base int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := i.ended(base)
+	for _, m := range i.dense[:n] {
+		last := len(i.sparse) - 1
+		if last >= 0 && m.offset-i.sparse[last].offset < markSpacing {
+			i.sparse[last].merged = true
+			continue
+		}
+		i.sparse = append(i.sparse, m)
+	}
+	i.dense = i.dense[n:]
+}
+
+// ended returns the number of the dense marks of the pieces
+// ending before the base offset. Should be called holding the lock
+func (i *index) ended(base int64) int {
+	n := 0
+	for n+1 < len(i.dense) && i.dense[n+1].offset <= base {
+		n++
+	}
+	return n
+}
+
+// len returns the number of the marks. Should be called holding the lock
+func (i *index) len() int {
+	return len(i.sparse) + len(i.dense)
+}
+
+// at returns the k-th mark counting both the sparse and the dense
+// ones in the order of the offset. Should be called holding the lock
+func (i *index) at(k int) mark {
+	if k < len(i.sparse) {
+		return i.sparse[k]
+	}
+	return i.dense[k-len(i.sparse)]
+}
+
+// lookup returns the mark of the piece containing the offset along
+// with the offset the piece ends at. Zero mark is returned if
+// the piece is not known, e.g. the marks are trimmed
+func (i *index) lookup(offset int64) (mark, int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// The first piece starting after the offset
+	n := sort.Search(i.len(), func(k int) bool {
+		return i.at(k).offset > offset
+	})
+
+	end := int64(math.MaxInt64)
+	if n < i.len() {
+		end = i.at(n).offset
+	}
+	if n == 0 {
+		return mark{}, end
+	}
+	return i.at(n - 1), end
+}
+
+// offsetOf returns the offset of the first piece having the sequence
+// number not less than the given one. The ok is false if there is no
+// such piece, i.e. everything known was published before it
+func (i *index) offsetOf(seq uint64) (offset int64, ok bool) {
+	return i.search(func(m mark) bool {
+		return m.seq >= seq
+	})
+}
+
+// offsetSince returns the offset of the first piece read not earlier
+// than the given moment. The ok is false if there is no such piece
+func (i *index) offsetSince(t time.Time) (offset int64, ok bool) {
+	since := t.UnixNano()
+	return i.search(func(m mark) bool {
+		return m.time >= since
+	})
+}
+
+// search returns the offset of the first piece satisfying the
+// predicate, which is false for some prefix of the pieces only.
+// The merged piece preceding it might contain such pieces as
+// well, so the offset of the merged piece is returned then
+func (i *index) search(f func(mark) bool) (int64, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := sort.Search(i.len(), func(k int) bool {
+		return f(i.at(k))
+	})
+	if n > 0 && i.at(n-1).merged {
+		return i.at(n - 1).offset, true
+	}
+	if n == i.len() {
+		return 0, false
+	}
+	return i.at(n).offset, true
+}
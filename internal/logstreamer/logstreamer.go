@@ -8,54 +8,159 @@ import (
 	"log"
 	"os"
 	"sync"
+	"syscall"
 	"time"
 )
 
 const defaultBufSize = 1024
 
+// buffer keeps the latest part of the output in memory
+type buffer interface {
+	io.Writer
+	// Len returns the number of the output bytes kept
+	Len() int
+	// Reader returns the reader of the bytes kept at the moment of the
+	// call, the oldest one being at the offset 0. Writing to the buffer
+	// afterwards doesn't affect it
+	Reader() io.ReaderAt
+	// Release frees the memory taken by the buffer
+	Release()
+}
+
 type LogStreamer struct {
-	reader  io.ReadCloser
-	broker  *Broker
-	buf     *SyncBuf
-	mu      sync.Mutex
-	streams []chan []byte
+	reader io.ReadCloser
+	broker *Broker
+
+	// mu guards the buffer and makes sure the new streams
+	// get every piece of the output exactly once
+	mu  sync.Mutex
+	buf buffer
+	// sink keeps the complete output if provided,
+	// written is the total size of the output so far
+	sink    Sink
+	written int64
+	// seq numbers the published pieces, index keeps where they start
+	seq   *Sequence
+	index *index
+
+	limit    int
+	budget   *Budget
+	compress bool
+	// queueLimit and policy configure the queues of the
+	// live output of the streams, see WithSubscriberQueue
+	queueLimit int
+	policy     SlowSubscriberPolicy
+
+	// format is the format of the output lines. The incomplete line
+	// starting at lineOffset is kept in partial until it is parsed
+	format         Format
+	partial        []byte
+	lineOffset     int64
+	parseErrors    int64
+	lastParseError *ParseError
+}
+
+// Option is function used for applying configurations to log streamer
+type Option func(*LogStreamer)
+
+// WithLimit limits the amount of the output kept in memory,
+// only the latest limit bytes of it are available for streaming
+func WithLimit(limit int) Option {
+	return func(s *LogStreamer) {
+		s.limit = limit
+	}
+}
+
+// WithBudget makes the log streamer share the memory budget with other ones
+func WithBudget(budget *Budget) Option {
+	return func(s *LogStreamer) {
+		s.budget = budget
+	}
 }
 
-func NewLogStreamer(reader io.ReadCloser) *LogStreamer {
-	broker := NewBroker()
-	sub := broker.Subscribe()
-	buf := NewSyncBuf(sub)
+// WithCompression makes the log streamer keep the output in memory
+// compressed, see CompressedBuf. The limit applies to the memory taken
+// by the compressed output then, so much more of it is available
+func WithCompression() Option {
+	return func(s *LogStreamer) {
+		s.compress = true
+	}
+}
 
+// WithSink makes the log streamer keep the complete output in the sink,
+// so the streams replay it from there instead of the memory buffer
+func WithSink(sink Sink) Option {
+	return func(s *LogStreamer) {
+		s.sink = sink
+	}
+}
+
+// WithSequence makes the log streamer number the output pieces using the
+// sequence shared with other ones, so their outputs can be merged in order
+func WithSequence(seq *Sequence) Option {
+	return func(s *LogStreamer) {
+		s.seq = seq
+	}
+}
+
+// WithSubscriberQueue limits the live output queued for every stream to
+// limit bytes. The streams which don't keep up with the output are handled
+// according to the policy, so the output is read at its own pace anyway
+func WithSubscriberQueue(limit int, policy SlowSubscriberPolicy) Option {
+	return func(s *LogStreamer) {
+		s.queueLimit = limit
+		s.policy = policy
+	}
+}
+
+func NewLogStreamer(reader io.ReadCloser, options ...Option) *LogStreamer {
 	ls := &LogStreamer{
-		reader:  reader,
-		broker:  broker,
-		buf:     buf,
-		streams: []chan []byte{},
+		reader: reader,
+		seq:    NewSequence(),
+		index:  &index{},
 	}
 
+	for _, opt := range options {
+		opt(ls)
+	}
+	if ls.compress {
+		ls.buf = NewCompressedBuf(ls.limit, ls.budget)
+	} else {
+		ls.buf = NewRingBuf(ls.limit, ls.budget)
+	}
+	ls.broker = NewBroker(ls.queueLimit, ls.policy)
+
 	go ls.readLogs()
-	go broker.Broadcast()
 
 	return ls
 }
 
 func (s *LogStreamer) readLogs() {
+	// Whatever the reason of the exit is, nothing is going to be
+	// published anymore, so the streams can be finished
+	defer s.broker.Stop()
+
+	var offset int64
 	for {
 		pack := make([]byte, defaultBufSize)
 		n, err := s.reader.Read(pack)
 		if n > 0 {
-			s.broker.Send(pack[:n])
+			s.publish(pack[:n], time.Now())
+			s.parse(pack[:n], offset)
+			offset += int64(n)
 		}
 
 		if errors.Is(err, io.EOF) {
-			// There is nothing left to read now, but the task is still
-			// running - so just chill for a second and try again
-			time.Sleep(time.Second)
-			continue
+			// All the writers are gone, i.e. the task and everything it
+			// might have spawned is finished, and the output is read till the end
+			break
 		} else if errors.Is(err, os.ErrClosed) {
 			// This is the expected behavior - ending up here means that
-			// that task was either finished or terminated and the reader
-			// was closed, so we just break the routine
+			// the reader was closed explicitly, so we just break the routine
+			break
+		} else if errors.Is(err, syscall.EIO) {
+			// Same as EOF, but for the terminal - reading its
+			// master side fails when all the processes are gone
 			break
 		} else if err != nil {
 			// Something unexpected happened. Write the error to the buffer
@@ -64,70 +169,300 @@ func (s *LogStreamer) readLogs() {
 			// Should hardly ever happen
 			readError := fmt.Sprintf("unexpected error while reading the task output: %v\n", err)
 			log.Println(readError)
-			s.broker.Send([]byte(readError))
+			s.publish([]byte(readError), time.Now())
 			break
 		}
 	}
+	if len(s.partial) > 0 {
+		// The last line is complete as nothing is going to be added to it
+		s.parseLine(s.partial)
+	}
+	s.reader.Close()
 }
 
+// publish stores the output read at the given moment
+// and delivers it to the active streams
+func (s *LogStreamer) publish(p []byte, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sink != nil {
+		_, err := s.sink.Write(p)
+		if err != nil {
+			// The sink is incomplete from now on, so
+			// only the memory buffer is used afterwards
+			log.Printf("error writing the output to the sink, dropping it: %v", err)
+			s.sink.Remove()
+			s.sink = nil
+		}
+	}
+
+	s.index.add(s.written, s.seq.next(), t)
+	s.buf.Write(p)
+	s.written += int64(len(p))
+	if s.sink == nil {
+		// The pieces not available anymore are not needed
+		s.index.trim(s.written - int64(s.buf.Len()))
+	} else {
+		s.index.thin(s.written - int64(s.buf.Len()))
+	}
+	s.broker.Send(p)
+}
+
+// Written returns the total size of the output so far
+func (s *LogStreamer) Written() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.written
+}
+
+// Close stops reading the task output. It is not required to be called when
+// the task is finished, as the output is read till the very end anyway
 func (s *LogStreamer) Close() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.reader.Close()
-	s.broker.Stop()
-	for _, stream := range s.streams {
-		close(stream)
+}
+
+// Chunk is a piece of the output along with its position in the output
+type Chunk struct {
+	// Offset is the position of the first byte of the
+	// chunk counting from the beginning of the output
+	Offset int64
+	Data   []byte
+	// Truncated is the number of bytes right before the chunk, which are
+	// not available anymore. Such chunks are sent with no data at all
+	Truncated int64
+	// Seq is the sequence number of the chunk, the chunks of the
+	// same piece of the output share it. Zero if it is not known
+	Seq uint64
+	// Source is the index of the stream the chunk
+	// comes from, it is only set by Merge
+	Source int
+	// Time is the moment the chunk piece was read
+	// from the output, zero if it is not known
+	Time time.Time
+	// Size is the amount of the output the chunk covers if it differs
+	// from the size of the data, e.g. the line is rendered by the filter
+	Size int64
+	// Err is the reason the stream is interrupted for, e.g.
+	// ErrSlowSubscriber. Such chunk is the last one of the stream
+	Err error
+}
+
+// StreamOptions define which part of the output is streamed
+type StreamOptions struct {
+	// From is the offset the stream starts from
+	From int64
+	// FromSeq makes the stream start from the first piece of the output
+	// having the sequence number not less than it, if it is later than From
+	FromSeq uint64
+	// Since makes the stream start from the first piece of the output read
+	// not earlier than it, while Until finishes the stream at the first
+	// piece read after it. Zero values don't limit the stream
+	Since time.Time
+	Until time.Time
+	// TailLines and TailBytes limit the output produced before
+	// the stream started to the last lines or bytes of it
+	TailLines int
+	TailBytes int64
+	// NoFollow makes the stream finish once the output produced
+	// before the stream started is sent, instead of waiting for more
+	NoFollow bool
+}
+
+// Stream streams everything the task has produced starting from the
+// offset requested and keeps streaming the upcoming logs until the
+// output is over. If some of the requested output was dropped due
+// to the limits, the stream starts with the chunk telling about that
+func (s *LogStreamer) Stream(ctx context.Context, opts StreamOptions) <-chan Chunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := &cursor{
+		offset: opts.From,
+		index:  s.index,
+	}
+	if c.offset < 0 {
+		c.offset = 0
+	}
+	if opts.FromSeq > 0 {
+		offset, ok := s.index.offsetOf(opts.FromSeq)
+		if !ok {
+			// Everything written so far precedes it
+			offset = s.written
+		}
+		if offset > c.offset {
+			c.offset = offset
+		}
+	}
+	if !opts.Since.IsZero() {
+		offset, ok := s.index.offsetSince(opts.Since)
+		if !ok {
+			offset = s.written
+		}
+		if offset > c.offset {
+			c.offset = offset
+		}
+	}
+	c.until = opts.Until
+
+	available, base := s.available()
+	if opts.TailBytes > 0 && s.written-opts.TailBytes > c.offset {
+		c.offset = s.written - opts.TailBytes
+	}
+	if opts.TailLines > 0 {
+		start, err := lineStart(available, base, s.written, opts.TailLines)
+		if err != nil {
+			log.Printf("unexpected error happened while looking for the last lines: %v", err)
+		} else if start > c.offset {
+			c.offset = start
+		}
+	}
+
+	if c.offset < s.written {
+		if c.offset < base {
+			c.truncated = base - c.offset
+			c.offset = base
+		}
+		c.replay = io.NewSectionReader(available, c.offset, s.written-c.offset)
+	}
+
+	if opts.NoFollow {
+		// Only the output produced so far is streamed
+		return s.stream(ctx, c)
 	}
+
+	c.live = s.broker.Subscribe()
+	c.liveOffset = s.written
+
+	return s.stream(ctx, c)
 }
 
-func (s *LogStreamer) Stream(ongoing bool, ctx context.Context) <-chan []byte {
+// Follow streams only the logs produced since the moment of the call
+func (s *LogStreamer) Follow(ctx context.Context) <-chan Chunk {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	ch := make(chan []byte)
-	s.streams = append(s.streams, ch)
+	c := &cursor{
+		offset:     s.written,
+		index:      s.index,
+		live:       s.broker.Subscribe(),
+		liveOffset: s.written,
+	}
+
+	return s.stream(ctx, c)
+}
+
+// cursor describes the position of the stream in the output
+type cursor struct {
+	// offset is the position of the next byte sent to the stream
+	offset    int64
+	truncated int64
+	// index splits the output into the pieces it was published by
+	index *index
+	// until is the moment the stream finishes at, if set
+	until time.Time
+	// replay provides the output produced before the stream started
+	replay io.Reader
+	// live is the output produced after the stream started, if the
+	// stream follows the output, liveOffset is the position of its first byte
+	live       *BrokerSub
+	liveOffset int64
+}
+
+// Release frees the memory taken by the output, which is not
+// available for streaming afterwards. Should be called once
+// the output is not needed anymore, e.g. the job is removed
+func (s *LogStreamer) Release() {
+	s.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.Release()
+
+	if s.sink != nil {
+		err := s.sink.Remove()
+		if err != nil {
+			log.Printf("error removing the output: %v", err)
+		}
+		s.sink = nil
+	}
+}
+
+// stream sends the replayed output first, if any, and then the live one
+func (s *LogStreamer) stream(ctx context.Context, c *cursor) <-chan Chunk {
+	ch := make(chan Chunk)
 
 	go func() {
-		bufCopy, sub := s.dupBuffer(ongoing)
+		defer close(ch)
+		if c.live != nil {
+			// If broker is not yet stopped, i.e. the task is still
+			// writing, stop receiving the updates
+			defer s.broker.Unsubscribe(c.live)
+		}
+
+		if c.truncated > 0 && !c.sendGap(ctx, ch, c.offset, c.truncated) {
+			return
+		}
 
+		if c.replay != nil && !c.send(ctx, ch, c.replay) {
+			return
+		}
+		if c.live == nil {
+			// The stream doesn't follow the output
+			return
+		}
+
+		// next is the position of the next live message
+		next := c.liveOffset
 		for {
-			select {
-			case <-ctx.Done():
-				if sub != nil {
-					// If broker is not yet closed, i.e. the task is still alive
-					s.broker.Unsubscribe(sub)
-				}
-				return
-			default:
-			}
+			// Check it before reading, otherwise the last updates
+			// might be queued right after we've found the queue empty
+			complete := c.live.Complete()
 
-			pack := make([]byte, defaultBufSize)
-			n, err := bufCopy.Read(pack)
-			if n > 0 {
-				ch <- pack[:n]
+			pack, dropped := c.live.Next()
+			if dropped > 0 {
+				// The stream didn't keep up with the output
+				next += dropped
+				if next > c.offset && !c.sendGap(ctx, ch, next, next-c.offset) {
+					return
+				}
 			}
 
-			if errors.Is(err, io.EOF) {
-				// If the task is not alive anymore and yet somebody
-				// wants to get logs - when we reach EOF we might as well
-				// close the stream and exit
-				if !ongoing {
-					close(ch)
+			if pack != nil {
+				start := next
+				next += int64(len(pack))
+				// The stream might be requested from the offset
+				// which is not yet written, so skip the data up to it
+				if next <= c.offset {
+					continue
+				}
+				if c.offset > start {
+					pack = pack[c.offset-start:]
+				}
+				if !c.sendChunk(ctx, ch, pack) {
 					return
 				}
-				// On the other hand, if the task is still in progress -
-				// the same logic as for the main buffer applies, keep
-				// reading even when reached EOF as the subscriber might
-				// add something to the buffer
-				time.Sleep(time.Second)
 				continue
-			} else if err != nil {
-				// Should hardly ever happen
-				streamError := fmt.Sprintf("unexpected error happened while streaming: %v\n", err)
-				log.Println(streamError)
-				s.broker.Send([]byte(streamError))
-				break
+			}
+
+			// If the task output is over and everything was already
+			// streamed - we might as well close the stream and exit
+			if complete {
+				if err := c.live.Err(); err != nil {
+					select {
+					case ch <- Chunk{Offset: c.offset, Err: err}:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			// On the other hand, if the task is still in progress -
+			// wait for something to be added to the queue
+			if c.live.Wait(ctx) != nil {
+				return
 			}
 		}
 	}()
@@ -135,12 +470,65 @@ func (s *LogStreamer) Stream(ongoing bool, ctx context.Context) <-chan []byte {
 	return ch
 }
 
-func (s *LogStreamer) dupBuffer(withUpdates bool) (*SyncBuf, *BrokerSub) {
-	if withUpdates {
-		sub := s.broker.Subscribe()
-		bufCopy := s.buf.CopyWithSub(sub)
-		return bufCopy, sub
+// sendGap tells the stream about the number of bytes right before the
+// offset, which are not available, and moves the cursor to the offset.
+// Returns whether the stream can be continued
+func (c *cursor) sendGap(ctx context.Context, ch chan<- Chunk, offset, truncated int64) bool {
+	select {
+	case ch <- Chunk{Offset: offset, Truncated: truncated}:
+		c.offset = offset
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// send sends everything from the reader to the stream.
+// Returns whether the stream can be continued
+func (c *cursor) send(ctx context.Context, ch chan<- Chunk, r io.Reader) bool {
+	for {
+		pack := make([]byte, defaultBufSize)
+		n, err := r.Read(pack)
+		if n > 0 && !c.sendChunk(ctx, ch, pack[:n]) {
+			return false
+		}
+
+		if errors.Is(err, io.EOF) {
+			return true
+		} else if err != nil {
+			log.Printf("unexpected error happened while replaying the output: %v", err)
+			return false
+		}
 	}
+}
 
-	return s.buf.Copy(), nil
+// sendChunk sends the data to the stream moving the cursor forward.
+// The data is split so every chunk belongs to a single published piece
+// and carries its number and time. Returns whether the stream can be continued
+func (c *cursor) sendChunk(ctx context.Context, ch chan<- Chunk, data []byte) bool {
+	for len(data) > 0 {
+		m, end := c.index.lookup(c.offset)
+		n := len(data)
+		if end-c.offset < int64(n) {
+			n = int(end - c.offset)
+		}
+
+		chunk := Chunk{Offset: c.offset, Data: data[:n], Seq: m.seq}
+		if m.time != 0 {
+			chunk.Time = time.Unix(0, m.time)
+		}
+		if !c.until.IsZero() && chunk.Time.After(c.until) {
+			// Everything afterwards is read later as well
+			return false
+		}
+
+		select {
+		case ch <- chunk:
+			c.offset += int64(n)
+			data = data[n:]
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,120 @@
+package logstreamer
+
+import (
+	"context"
+	"time"
+)
+
+// mergeDelay is how long the merged stream waits for the missing
+// pieces of the output before sending the following ones anyway
+const mergeDelay = 100 * time.Millisecond
+
+// Merge combines the streams of the log streamers sharing the same
+// sequence into a single one ordered by the sequence numbers. The
+// chunks are tagged with the index of the stream they come from.
+//
+// Every piece of the output is expected to come from one of the
+// streams, so the pieces are sent as soon as the previous one was.
+// If it is not possible to tell, e.g. the previous piece was
+// truncated, the stream waits for the other ones for a short while
+func Merge(ctx context.Context, streams ...<-chan Chunk) <-chan Chunk {
+	type incoming struct {
+		source int
+		chunk  Chunk
+		ok     bool
+	}
+
+	in := make(chan incoming)
+	for i, stream := range streams {
+		go func(source int, stream <-chan Chunk) {
+			for {
+				chunk, ok := <-stream
+				select {
+				case in <- incoming{source: source, chunk: chunk, ok: ok}:
+				case <-ctx.Done():
+					return
+				}
+				if !ok {
+					return
+				}
+			}
+		}(i, stream)
+	}
+
+	ch := make(chan Chunk)
+	go func() {
+		defer close(ch)
+
+		pending := make([][]Chunk, len(streams))
+		closed := make([]bool, len(streams))
+		open := len(streams)
+		// next is the number of the piece expected to be sent next
+		next := uint64(1)
+
+		// ready returns the stream the next chunk is to be sent from,
+		// -1 if there is none. Waiting tells whether the missing pieces
+		// might still come from the streams having no chunks pending
+		ready := func() (source int, waiting bool) {
+			source = -1
+			for i, chunks := range pending {
+				if len(chunks) == 0 {
+					waiting = waiting || !closed[i]
+					continue
+				}
+				if source == -1 || chunks[0].Seq < pending[source][0].Seq {
+					source = i
+				}
+			}
+			return source, waiting
+		}
+
+		var timeout <-chan time.Time
+		expired := false
+		for {
+			source, waiting := ready()
+			if source == -1 && open == 0 {
+				return
+			}
+
+			if source != -1 {
+				chunk := pending[source][0]
+				// The chunks of the same piece share the number, while
+				// the ones without it (i.e. the truncation notices)
+				// don't affect the order of the others
+				if chunk.Seq <= next || !waiting || expired {
+					select {
+					case ch <- chunk:
+					case <-ctx.Done():
+						return
+					}
+					pending[source] = pending[source][1:]
+					if chunk.Seq >= next {
+						next = chunk.Seq + 1
+					}
+					timeout, expired = nil, false
+					continue
+				}
+				if timeout == nil {
+					timeout = time.After(mergeDelay)
+				}
+			}
+
+			select {
+			case msg := <-in:
+				if !msg.ok {
+					closed[msg.source] = true
+					open--
+					continue
+				}
+				msg.chunk.Source = msg.source
+				pending[msg.source] = append(pending[msg.source], msg.chunk)
+			case <-timeout:
+				expired = true
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
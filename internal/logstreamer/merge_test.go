@@ -0,0 +1,99 @@
+package logstreamer
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeKeepsTheOutputOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		live bool
+	}{
+		{name: "replayed"},
+		{name: "live", live: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := NewSequence()
+			outReader, outWriter := io.Pipe()
+			errReader, errWriter := io.Pipe()
+			out := NewLogStreamer(outReader, WithSequence(seq))
+			errs := NewLogStreamer(errReader, WithSequence(seq))
+
+			stream := func() <-chan Chunk {
+				return Merge(context.Background(),
+					out.Stream(context.Background(), StreamOptions{}),
+					errs.Stream(context.Background(), StreamOptions{}),
+				)
+			}
+
+			var ch <-chan Chunk
+			if tt.live {
+				ch = stream()
+			}
+
+			writes := []struct {
+				writer *io.PipeWriter
+				s      *LogStreamer
+				data   string
+			}{
+				{outWriter, out, "one "},
+				{errWriter, errs, "two "},
+				{errWriter, errs, "three "},
+				{outWriter, out, "four"},
+			}
+			for _, w := range writes {
+				written := w.s.Written()
+				w.writer.Write([]byte(w.data))
+				// Make sure the piece is published before the next one
+				for w.s.Written() == written {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			outWriter.Close()
+			errWriter.Close()
+
+			if !tt.live {
+				ch = stream()
+			}
+
+			var output, sources strings.Builder
+			var last uint64
+			for chunk := range ch {
+				assert.Greater(t, chunk.Seq, last)
+				last = chunk.Seq
+				output.Write(chunk.Data)
+				sources.WriteString(map[int]string{0: "o", 1: "e"}[chunk.Source])
+			}
+			assert.Equal(t, "one two three four", output.String())
+			assert.Equal(t, "oeeo", sources.String())
+		})
+	}
+}
+
+func TestStreamFromSequenceNumber(t *testing.T) {
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader)
+
+	for _, data := range []string{"ab", "cd", "ef"} {
+		written := s.Written()
+		writer.Write([]byte(data))
+		for s.Written() == written {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	writer.Close()
+
+	chunks := collect(s.Stream(context.Background(), StreamOptions{FromSeq: 2}))
+	assert.Equal(t, []Chunk{
+		{Offset: 2, Data: []byte("cd"), Seq: 2},
+		{Offset: 4, Data: []byte("ef"), Seq: 3},
+	}, chunks)
+}
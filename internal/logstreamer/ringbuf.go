@@ -0,0 +1,127 @@
+package logstreamer
+
+import (
+	"bytes"
+	"io"
+)
+
+// minRetained is the amount of the latest output every buffer is allowed to
+// keep even when the memory budget is exhausted, otherwise the jobs started
+// on the loaded server would have no output to show at all
+const minRetained = 64 * 1024
+
+// RingBuf keeps the last bytes written to it, dropping the oldest
+// ones once the limit is reached. The memory is allocated as the
+// data comes, so the limit doesn't cost anything for quiet jobs.
+// RingBuf is not safe for concurrent use
+type RingBuf struct {
+	buf []byte
+	// limit is the maximum number of bytes kept, 0 means unlimited
+	limit int
+	// full is set once the buffer has reached its limit, since
+	// then pos points to both the oldest byte and the next write
+	full bool
+	pos  int
+
+	budget *Budget
+	// truncated is the number of bytes dropped since the beginning
+	truncated int64
+}
+
+func NewRingBuf(limit int, budget *Budget) *RingBuf {
+	return &RingBuf{
+		limit:  limit,
+		budget: budget,
+	}
+}
+
+func (r *RingBuf) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if !r.full {
+		grow := len(p)
+		if r.limit > 0 && len(r.buf)+grow > r.limit {
+			grow = r.limit - len(r.buf)
+		}
+		if !r.budget.reserve(grow) {
+			// The budget is exhausted, so the buffer stops growing
+			// and starts dropping its oldest data instead
+			r.shrinkLimit()
+			grow = r.limit - len(r.buf)
+			if grow > len(p) {
+				grow = len(p)
+			}
+			r.budget.force(grow)
+		}
+
+		r.buf = append(r.buf, p[:grow]...)
+		p = p[grow:]
+		if r.limit > 0 && len(r.buf) == r.limit {
+			r.full = true
+			r.pos = 0
+		}
+	}
+
+	if len(p) == 0 {
+		return n, nil
+	}
+
+	// Only the last bytes fit in the buffer
+	if len(p) > r.limit {
+		r.truncated += int64(len(p) - r.limit)
+		p = p[len(p)-r.limit:]
+	}
+	r.truncated += int64(len(p))
+
+	written := copy(r.buf[r.pos:], p)
+	copy(r.buf, p[written:])
+	r.pos = (r.pos + len(p)) % r.limit
+
+	return n, nil
+}
+
+// shrinkLimit limits the buffer by its current size, as it is not
+// allowed to grow anymore, keeping at least the minimal retained amount
+func (r *RingBuf) shrinkLimit() {
+	limit := len(r.buf)
+	if limit < minRetained {
+		limit = minRetained
+	}
+	if r.limit == 0 || limit < r.limit {
+		r.limit = limit
+	}
+}
+
+// Len returns the number of bytes kept in the buffer
+func (r *RingBuf) Len() int {
+	return len(r.buf)
+}
+
+// Truncated returns the number of bytes dropped since the beginning
+func (r *RingBuf) Truncated() int64 {
+	return r.truncated
+}
+
+// Bytes returns the copy of the data kept in the buffer, oldest first
+func (r *RingBuf) Bytes() []byte {
+	cp := make([]byte, 0, len(r.buf))
+	if !r.full {
+		return append(cp, r.buf...)
+	}
+
+	cp = append(cp, r.buf[r.pos:]...)
+	return append(cp, r.buf[:r.pos]...)
+}
+
+// Reader returns the reader of the copy of the data kept in the buffer
+func (r *RingBuf) Reader() io.ReaderAt {
+	return bytes.NewReader(r.Bytes())
+}
+
+// Release frees the buffer memory and returns it to the budget
+func (r *RingBuf) Release() {
+	r.budget.release(len(r.buf))
+	r.buf = nil
+	r.full = false
+	r.pos = 0
+}
@@ -0,0 +1,103 @@
+package logstreamer
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingBufKeepsLatestBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		writes    []string
+		expected  string
+		truncated int64
+	}{
+		{
+			name:     "unlimited",
+			writes:   []string{"hello ", "world"},
+			expected: "hello world",
+		},
+		{
+			name:     "within limit",
+			limit:    16,
+			writes:   []string{"hello ", "world"},
+			expected: "hello world",
+		},
+		{
+			name:      "wraps around",
+			limit:     8,
+			writes:    []string{"hello ", "world", "!"},
+			expected:  "o world!",
+			truncated: 4,
+		},
+		{
+			name:      "write larger than limit",
+			limit:     4,
+			writes:    []string{"ab", "cdefghij"},
+			expected:  "ghij",
+			truncated: 6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRingBuf(tt.limit, nil)
+			for _, w := range tt.writes {
+				r.Write([]byte(w))
+			}
+			assert.Equal(t, tt.expected, string(r.Bytes()))
+			assert.Equal(t, tt.truncated, r.Truncated())
+		})
+	}
+}
+
+func TestRingBufRespectsBudget(t *testing.T) {
+	budget := NewBudget(minRetained)
+
+	first := NewRingBuf(0, budget)
+	first.Write(make([]byte, minRetained))
+	assert.Equal(t, int64(minRetained), budget.Used())
+
+	// The budget is exhausted, but the minimal amount is still kept
+	second := NewRingBuf(0, budget)
+	second.Write(make([]byte, 2*minRetained))
+	assert.Equal(t, minRetained, second.Len())
+	assert.Equal(t, int64(minRetained), second.Truncated())
+
+	first.Release()
+	second.Release()
+	assert.Equal(t, int64(0), budget.Used())
+}
+
+func TestStreamStartsWithTruncatedChunk(t *testing.T) {
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader, WithLimit(4))
+
+	writer.Write([]byte("abcdefgh"))
+	writer.Close()
+	// Wait for the output to be over, so everything is replayed
+	for range s.Stream(context.Background(), StreamOptions{}) {
+	}
+
+	chunks := collect(s.Stream(context.Background(), StreamOptions{From: 1}))
+	assert.Equal(t, []Chunk{
+		{Offset: 4, Truncated: 3},
+		{Offset: 4, Data: []byte("efgh"), Seq: 1},
+	}, chunks)
+}
+
+// collect reads the whole stream dropping the chunk times,
+// which are different every run
+func collect(ch <-chan Chunk) []Chunk {
+	var chunks []Chunk
+	for chunk := range ch {
+		chunk.Time = time.Time{}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
@@ -0,0 +1,68 @@
+package logstreamer
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Sink keeps the complete output, while the log streamer
+// itself keeps in memory only the latest part of it
+type Sink interface {
+	io.Writer
+	// ReaderAt provides access to everything written so far
+	io.ReaderAt
+	// Remove deletes the stored output, the sink
+	// is not supposed to be used afterwards
+	Remove() error
+}
+
+// FileSink appends the output to the file
+type FileSink struct {
+	file *os.File
+	// readOnly is set for the sink over the file written
+	// by somebody else, see OpenFileSink
+	readOnly bool
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error creating log file: %w", err)
+	}
+
+	return &FileSink{
+		file: file,
+	}, nil
+}
+
+// OpenFileSink uses the file written by somebody else as the sink. The log
+// streamer is supposed to read its output from the same file, so whatever
+// is written to the sink is already there and is not written once again
+func OpenFileSink(path string) (*FileSink, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %w", err)
+	}
+
+	return &FileSink{
+		file:     file,
+		readOnly: true,
+	}, nil
+}
+
+func (s *FileSink) Write(p []byte) (int, error) {
+	if s.readOnly {
+		return len(p), nil
+	}
+	return s.file.Write(p)
+}
+
+func (s *FileSink) ReadAt(p []byte, off int64) (int, error) {
+	return s.file.ReadAt(p, off)
+}
+
+func (s *FileSink) Remove() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
@@ -0,0 +1,125 @@
+package logstreamer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamReplaysSinkThenLive(t *testing.T) {
+	// The memory keeps only the latest part, but the
+	// complete output is still available in the sink
+	path := filepath.Join(t.TempDir(), "stdout.log")
+	sink, err := NewFileSink(path)
+	require.NoError(t, err)
+
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader, WithLimit(4), WithSink(sink))
+
+	writer.Write([]byte("abcdefgh"))
+	// Make sure the stream starts with some output
+	// replayed and the rest of it comes live
+	for s.Written() < 8 {
+		time.Sleep(time.Millisecond)
+	}
+	ch := s.Stream(context.Background(), StreamOptions{From: 2})
+	writer.Write([]byte("ijkl"))
+	writer.Close()
+
+	var out strings.Builder
+	next := int64(2)
+	for chunk := range ch {
+		// The chunks go one after another with no gaps
+		assert.Equal(t, next, chunk.Offset)
+		next += int64(len(chunk.Data))
+		out.Write(chunk.Data)
+	}
+	assert.Equal(t, "cdefghijkl", out.String())
+
+	s.Release()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStreamFromOffsetNotYetWritten(t *testing.T) {
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader)
+
+	ch := s.Stream(context.Background(), StreamOptions{From: 3})
+	writer.Write([]byte("ab"))
+	writer.Write([]byte("cdef"))
+	writer.Close()
+
+	chunks := collect(ch)
+	assert.Equal(t, []Chunk{{Offset: 3, Data: []byte("def"), Seq: 2}}, chunks)
+}
+
+func TestStreamReadsOpenedSink(t *testing.T) {
+	// The file is written by somebody else,
+	// the streamer reads and serves it
+	path := filepath.Join(t.TempDir(), "stdout.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("abcdefgh"), 0600))
+
+	sink, err := OpenFileSink(path)
+	require.NoError(t, err)
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	s := NewLogStreamer(file, WithLimit(4), WithSink(sink))
+
+	var out strings.Builder
+	for chunk := range s.Stream(context.Background(), StreamOptions{}) {
+		out.Write(chunk.Data)
+	}
+	assert.Equal(t, "abcdefgh", out.String())
+
+	// The output is not written to the file once again
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefgh", string(data))
+}
+
+func TestIndexOfSinkOutputIsThinned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stdout.log")
+	sink, err := NewFileSink(path)
+	require.NoError(t, err)
+
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader, WithLimit(4096), WithSink(sink))
+
+	piece := []byte(strings.Repeat("x", 1023) + "\n")
+	pieces := 1024
+	for k := 0; k < pieces; k++ {
+		writer.Write(piece)
+	}
+	writer.Close()
+	for s.Written() < int64(pieces*len(piece)) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Only the marks of the output kept in memory are all there
+	s.index.mu.Lock()
+	marks := s.index.len()
+	s.index.mu.Unlock()
+	assert.LessOrEqual(t, marks, pieces*len(piece)/markSpacing+4096/len(piece)+2)
+
+	var out strings.Builder
+	for chunk := range s.Stream(context.Background(), StreamOptions{NoFollow: true}) {
+		out.Write(chunk.Data)
+	}
+	assert.Equal(t, strings.Repeat(string(piece), pieces), out.String())
+
+	// The piece merged into the thinned one is
+	// streamed from the beginning of that one
+	chunks := collect(s.Stream(context.Background(), StreamOptions{FromSeq: 100, NoFollow: true}))
+	require.NotEmpty(t, chunks)
+	assert.LessOrEqual(t, chunks[0].Offset, int64(99*len(piece)))
+	assert.LessOrEqual(t, chunks[0].Seq, uint64(100))
+}
@@ -0,0 +1,239 @@
+package logstreamer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format is the format of the output lines
+type Format int
+
+const (
+	// FormatText is the plain text output
+	FormatText Format = iota
+	// FormatJSON is the output of JSON objects, one per line
+	FormatJSON
+)
+
+// WithFormat makes the log streamer parse the output lines in the format,
+// the lines which can't be parsed are counted, see ParseErrors
+func WithFormat(format Format) Option {
+	return func(s *LogStreamer) {
+		s.format = format
+	}
+}
+
+// ParseError describes the output line which can't be parsed
+type ParseError struct {
+	// Offset is the position of the line in the output
+	Offset int64
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error parsing the line at offset %d: %v", e.Offset, e.Err)
+}
+
+// ParseErrors returns the number of the output lines which couldn't be
+// parsed according to the format along with the error of the last one
+func (s *LogStreamer) ParseErrors() (int64, *ParseError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.parseErrors, s.lastParseError
+}
+
+// parse checks the complete lines of the output piece starting at the
+// offset, the incomplete line is kept until the rest of it comes
+func (s *LogStreamer) parse(p []byte, offset int64) {
+	if s.format != FormatJSON {
+		return
+	}
+
+	for len(p) > 0 {
+		if len(s.partial) == 0 {
+			s.lineOffset = offset
+		}
+		n := bytes.IndexByte(p, '\n') + 1
+		if n == 0 {
+			if len(s.partial)+len(p) <= maxLineSize {
+				s.partial = append(s.partial, p...)
+				return
+			}
+			// Too long to wait for the end of it
+			n = len(p)
+		}
+
+		s.partial = append(s.partial, p[:n]...)
+		p = p[n:]
+		offset += int64(n)
+		s.parseLine(s.partial)
+		s.partial = s.partial[:0]
+	}
+}
+
+// parseLine records the error if the complete line is not a JSON object
+func (s *LogStreamer) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	var obj map[string]json.RawMessage
+	err := json.Unmarshal(line, &obj)
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.parseErrors++
+	s.lastParseError = &ParseError{Offset: s.lineOffset, Err: err}
+}
+
+// Predicate is the condition on the field of the JSON line,
+// e.g. level=error or latency_ms>500
+type Predicate struct {
+	// Field is the name of the field, the nested
+	// fields are separated by dots, e.g. http.status
+	Field string
+	Op    string
+	Value string
+}
+
+// predicateOps are the supported operators, the longer ones go first
+var predicateOps = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// ParsePredicate parses the predicate in the form of field, operator
+// and value, the operator being one of =, !=, >, >=, < and <=
+func ParsePredicate(expr string) (Predicate, error) {
+	for i := range expr {
+		for _, op := range predicateOps {
+			if strings.HasPrefix(expr[i:], op) {
+				p := Predicate{
+					Field: strings.TrimSpace(expr[:i]),
+					Op:    op,
+					Value: strings.TrimSpace(expr[i+len(op):]),
+				}
+				if p.Field == "" {
+					return Predicate{}, fmt.Errorf("no field in predicate %s", expr)
+				}
+				return p, nil
+			}
+		}
+	}
+	return Predicate{}, fmt.Errorf("no operator in predicate %s", expr)
+}
+
+// match checks the predicate against the parsed line. The values are
+// compared as numbers if both of them are numbers, as strings otherwise
+func (p Predicate) match(obj map[string]interface{}) bool {
+	value, ok := lookupField(obj, p.Field)
+	if !ok {
+		return p.Op == "!="
+	}
+
+	actual := fieldString(value)
+	if a, err := strconv.ParseFloat(actual, 64); err == nil {
+		if b, err := strconv.ParseFloat(p.Value, 64); err == nil {
+			return compare(p.Op, cmpFloat(a, b))
+		}
+	}
+	return compare(p.Op, strings.Compare(actual, p.Value))
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compare applies the operator to the result of the comparison
+func compare(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// lookupField returns the value of the possibly nested field
+func lookupField(obj map[string]interface{}, field string) (interface{}, bool) {
+	var value interface{} = obj
+	for _, name := range strings.Split(field, ".") {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = nested[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// fieldString returns the textual representation of the field value
+func fieldString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return "null"
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// parseObject parses the JSON line, the numbers are kept as they are
+func parseObject(line []byte) (map[string]interface{}, bool) {
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+
+	var obj map[string]interface{}
+	if d.Decode(&obj) != nil || obj == nil {
+		return nil, false
+	}
+	return obj, true
+}
+
+// selectFields returns the line containing only the given fields of the
+// object in the same order, the missing ones are skipped
+func selectFields(obj map[string]interface{}, fields []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, field := range fields {
+		value, ok := lookupField(obj, field)
+		if !ok {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(field)
+		data, _ := json.Marshal(value)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(data)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,123 @@
+package logstreamer
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePredicate(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected Predicate
+		err      bool
+	}{
+		{expr: "level=error", expected: Predicate{Field: "level", Op: "=", Value: "error"}},
+		{expr: "latency_ms>500", expected: Predicate{Field: "latency_ms", Op: ">", Value: "500"}},
+		{expr: "latency_ms >= 500", expected: Predicate{Field: "latency_ms", Op: ">=", Value: "500"}},
+		{expr: "http.status!=200", expected: Predicate{Field: "http.status", Op: "!=", Value: "200"}},
+		{expr: "level", err: true},
+		{expr: "=error", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := ParsePredicate(tt.expr)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+func TestFilterJSONLines(t *testing.T) {
+	const output = `{"level":"info","msg":"started","latency_ms":20}
+{"level":"error","msg":"failed","latency_ms":700,"http":{"status":500}}
+not a json line
+{"level":"error","msg":"slow","latency_ms":90}
+`
+
+	where := func(exprs ...string) []Predicate {
+		var predicates []Predicate
+		for _, expr := range exprs {
+			p, err := ParsePredicate(expr)
+			assert.NoError(t, err)
+			predicates = append(predicates, p)
+		}
+		return predicates
+	}
+
+	tests := []struct {
+		name     string
+		filter   LineFilter
+		expected []string
+	}{
+		{
+			name:   "string field",
+			filter: LineFilter{Where: where("level=error")},
+			expected: []string{
+				`{"level":"error","msg":"failed","latency_ms":700,"http":{"status":500}}` + "\n",
+				`{"level":"error","msg":"slow","latency_ms":90}` + "\n",
+			},
+		},
+		{
+			name:   "numeric field",
+			filter: LineFilter{Where: where("level=error", "latency_ms>500")},
+			expected: []string{
+				`{"level":"error","msg":"failed","latency_ms":700,"http":{"status":500}}` + "\n",
+			},
+		},
+		{
+			name:   "nested field",
+			filter: LineFilter{Where: where("http.status>=500")},
+			expected: []string{
+				`{"level":"error","msg":"failed","latency_ms":700,"http":{"status":500}}` + "\n",
+			},
+		},
+		{
+			name:   "selected fields",
+			filter: LineFilter{Where: where("latency_ms<100"), Fields: []string{"msg", "latency_ms", "missing"}},
+			expected: []string{
+				`{"msg":"started","latency_ms":20}` + "\n",
+				`{"msg":"slow","latency_ms":90}` + "\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan Chunk, 1)
+			in <- Chunk{Data: []byte(output)}
+			close(in)
+
+			var lines []string
+			for chunk := range FilterLines(context.Background(), in, tt.filter) {
+				lines = append(lines, string(chunk.Data))
+			}
+			assert.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestJSONParseErrors(t *testing.T) {
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader, WithFormat(FormatJSON))
+
+	writer.Write([]byte(`{"level":"info"}` + "\n" + `{"level":`))
+	writer.Write([]byte(`"error"}` + "\nbroken\n"))
+	writer.Write([]byte(`[1, 2]`))
+	writer.Close()
+
+	// The stream is over once the output is read
+	for range s.Stream(context.Background(), StreamOptions{}) {
+	}
+
+	count, last := s.ParseErrors()
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, int64(42), last.Offset)
+}
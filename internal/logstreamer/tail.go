@@ -0,0 +1,66 @@
+package logstreamer
+
+import "io"
+
+// offsetReader provides access to the part of the output
+// kept in memory using the offsets of the whole output
+type offsetReader struct {
+	r    io.ReaderAt
+	base int64
+}
+
+func (r *offsetReader) ReadAt(p []byte, off int64) (int, error) {
+	return r.r.ReadAt(p, off-r.base)
+}
+
+// available returns the reader of the output available for streaming,
+// which covers the offsets starting from the returned base one.
+// Should be called holding the lock
+func (s *LogStreamer) available() (io.ReaderAt, int64) {
+	if s.sink != nil {
+		// Everything up to this point is in the sink already
+		return s.sink, 0
+	}
+
+	base := s.written - int64(s.buf.Len())
+	return &offsetReader{
+		r:    s.buf.Reader(),
+		base: base,
+	}, base
+}
+
+// lineStart finds the offset the last n lines of the output start at, the
+// output being available in the reader starting from base up to the end.
+// If there are less lines available, the base offset is returned
+func lineStart(r io.ReaderAt, base, end int64, n int) (int64, error) {
+	const blockSize = 4096
+
+	block := make([]byte, blockSize)
+	pos := end
+	for pos > base {
+		size := int64(blockSize)
+		if pos-base < size {
+			size = pos - base
+		}
+		pos -= size
+
+		_, err := r.ReadAt(block[:size], pos)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		for i := size - 1; i >= 0; i-- {
+			// The newline ending the output ends
+			// the last line, it doesn't start a new one
+			if block[i] != '\n' || pos+i == end-1 {
+				continue
+			}
+			n--
+			if n == 0 {
+				return pos + i + 1, nil
+			}
+		}
+	}
+
+	return base, nil
+}
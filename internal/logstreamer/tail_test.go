@@ -0,0 +1,104 @@
+package logstreamer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		lines    int
+		expected string
+	}{
+		{
+			name:     "last lines",
+			data:     "one\ntwo\nthree\n",
+			lines:    2,
+			expected: "two\nthree\n",
+		},
+		{
+			name:     "unfinished last line",
+			data:     "one\ntwo\nthree",
+			lines:    2,
+			expected: "two\nthree",
+		},
+		{
+			name:     "less lines than requested",
+			data:     "one\ntwo\n",
+			lines:    5,
+			expected: "one\ntwo\n",
+		},
+		{
+			name:     "lines longer than block",
+			data:     strings.Repeat("a", 5000) + "\n" + strings.Repeat("b", 5000) + "\n",
+			lines:    1,
+			expected: strings.Repeat("b", 5000) + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Make sure the offsets are not confused with
+			// the positions in the part kept in memory
+			const base = 100
+			r := &offsetReader{r: bytes.NewReader([]byte(tt.data)), base: base}
+			end := int64(base + len(tt.data))
+
+			start, err := lineStart(r, base, end, tt.lines)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tt.data[start-base:])
+		})
+	}
+}
+
+func TestStreamTailWithoutFollowing(t *testing.T) {
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader)
+	defer writer.Close()
+
+	writer.Write([]byte("one\ntwo\nthree\n"))
+	for s.Written() < 14 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The job keeps running, but the stream is over anyway
+	var out strings.Builder
+	for chunk := range s.Stream(context.Background(), StreamOptions{TailLines: 2, NoFollow: true}) {
+		out.Write(chunk.Data)
+	}
+	assert.Equal(t, "two\nthree\n", out.String())
+}
+
+func TestStreamTimeRange(t *testing.T) {
+	reader, writer := io.Pipe()
+	s := NewLogStreamer(reader)
+
+	var moments []time.Time
+	for _, data := range []string{"ab", "cd", "ef"} {
+		time.Sleep(10 * time.Millisecond)
+		moments = append(moments, time.Now())
+
+		written := s.Written()
+		writer.Write([]byte(data))
+		for s.Written() == written {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	writer.Close()
+
+	var out bytes.Buffer
+	opts := StreamOptions{Since: moments[1], Until: moments[2]}
+	for chunk := range s.Stream(context.Background(), opts) {
+		assert.False(t, chunk.Time.Before(moments[1]))
+		out.Write(chunk.Data)
+	}
+	assert.Equal(t, "cd", out.String())
+}
@@ -0,0 +1,200 @@
+package teleworker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// archiveMetadata describes the job in the archive of its output
+type archiveMetadata struct {
+	ID        string           `json:"id"`
+	User      string           `json:"user"`
+	Command   string           `json:"command"`
+	Args      []string         `json:"args"`
+	Status    string           `json:"status"`
+	ExitCode  int              `json:"exit_code"`
+	Error     string           `json:"error,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	ExitedAt  *time.Time       `json:"exited_at,omitempty"`
+	MemoryMB  int              `json:"memory_limit_mb"`
+	CpuWeight int              `json:"cpu_limit_percentage"`
+	IOWeight  int              `json:"io_limit_percentage"`
+	Nice      int              `json:"nice"`
+	LogFormat string           `json:"log_format"`
+	Attempts  []archiveAttempt `json:"attempts"`
+	// Truncated is the number of the bytes of every output
+	// which were not available anymore when it was archived
+	Truncated  map[string]int64 `json:"truncated"`
+	ArchivedAt time.Time        `json:"archived_at"`
+}
+
+type archiveAttempt struct {
+	ExitCode  int        `json:"exit_code"`
+	StartedAt time.Time  `json:"started_at"`
+	ExitedAt  *time.Time `json:"exited_at,omitempty"`
+}
+
+// exitTime returns nil for the zero time, so the exit time
+// is left out of the metadata until the process has exited
+func exitTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// ExportLogs writes the gzipped tar archive containing the complete stdout
+// and stderr of the job along with metadata.json describing it. The output
+// available at the moment of the call is archived, so it works both
+// for the running jobs and the finished ones kept around. The part of
+// the output which is not retained anymore is reported in the metadata
+func (j *Job) ExportLogs(ctx context.Context, w io.Writer) error {
+	state := j.Status()
+
+	meta := archiveMetadata{
+		ID:         j.ID.String(),
+		User:       j.User,
+		Command:    j.UserCommand,
+		Args:       j.UserArgs,
+		Status:     state.Status.String(),
+		ExitCode:   state.ExitCode,
+		CreatedAt:  state.CreatedAt,
+		ExitedAt:   exitTime(state.ExitedAt),
+		MemoryMB:   state.Limits.MemoryMB,
+		CpuWeight:  state.Limits.CpuWeight,
+		IOWeight:   state.Limits.IOWeight,
+		Nice:       state.Nice,
+		LogFormat:  "text",
+		Truncated:  make(map[string]int64),
+		ArchivedAt: time.Now(),
+	}
+	if state.ExitErr != nil {
+		meta.Error = state.ExitErr.Error()
+	}
+	for _, a := range state.Attempts {
+		meta.Attempts = append(meta.Attempts, archiveAttempt{
+			ExitCode:  a.ExitCode,
+			StartedAt: a.StartedAt,
+			ExitedAt:  exitTime(a.ExitedAt),
+		})
+	}
+	if j.logFormat == LogJSON {
+		meta.LogFormat = "json"
+	}
+
+	outputs := []struct {
+		name   string
+		stream func(StreamOptions) (<-chan Chunk, context.CancelFunc)
+	}{
+		{name: "stdout.log", stream: j.StreamStdout},
+		{name: "stderr.log", stream: j.StreamStderr},
+	}
+
+	// The size of the file has to be known before it is written to the
+	// archive, so the outputs are collected to the temporary files first
+	files := make([]*os.File, 0, len(outputs))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	for _, output := range outputs {
+		f, err := ioutil.TempFile("", "teleworker-export-")
+		if err != nil {
+			return fmt.Errorf("error creating temporary file: %w", err)
+		}
+		files = append(files, f)
+
+		ch, cancel := output.stream(StreamOptions{NoFollow: true})
+		truncated, err := collectOutput(ctx, ch, f)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("error collecting %s: %w", output.name, err)
+		}
+		meta.Truncated[output.name] = truncated
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding metadata: %w", err)
+	}
+	err = writeArchiveFile(tw, "metadata.json", int64(len(data)), meta.CreatedAt, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	for i, f := range files {
+		size, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		_, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		err = writeArchiveFile(tw, outputs[i].name, size, meta.CreatedAt, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return fmt.Errorf("error finishing the archive: %w", err)
+	}
+	return gz.Close()
+}
+
+// collectOutput writes the streamed output to the writer
+// and returns the number of the bytes which were truncated
+func collectOutput(ctx context.Context, ch <-chan Chunk, w io.Writer) (int64, error) {
+	var truncated int64
+	for {
+		select {
+		case chunk, ok := <-ch:
+			if !ok {
+				return truncated, nil
+			}
+			if chunk.Err != nil {
+				return truncated, chunk.Err
+			}
+			truncated += chunk.Truncated
+			_, err := w.Write(chunk.Data)
+			if err != nil {
+				return truncated, err
+			}
+		case <-ctx.Done():
+			return truncated, ctx.Err()
+		}
+	}
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, size int64, modTime time.Time, r io.Reader) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: modTime,
+	})
+	if err != nil {
+		return fmt.Errorf("error writing %s to the archive: %w", name, err)
+	}
+
+	_, err = io.CopyN(tw, r, size)
+	if err != nil {
+		return fmt.Errorf("error writing %s to the archive: %w", name, err)
+	}
+	return nil
+}
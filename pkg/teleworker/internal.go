@@ -4,6 +4,9 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/alexflint/go-arg"
 	cg "github.com/spirifoxy/teleworker/pkg/cgroup"
@@ -16,9 +19,17 @@ import (
 // grpc as usual, no additional setup needed.
 // See Job.selfWrapCommand for more details
 func InternalCallHandle() {
+	// The shim runs the usual self call, so its own flag comes first.
+	// It is checked by hand, as the arguments of the self call
+	// following it contain the separator of the user arguments
+	if len(os.Args) > 1 && strings.HasPrefix(os.Args[1], shimFlag) {
+		runShim(strings.TrimPrefix(os.Args[1], shimFlag), os.Args[2:])
+	}
+
 	var internal struct {
 		Command string `arg:"required"`
 		JobID   string `arg:"required"`
+		Nice    int
 		Limits
 		Args []string `arg:"positional"`
 	}
@@ -38,9 +49,28 @@ func InternalCallHandle() {
 		os.Exit(1)
 	}
 
+	if internal.Nice != 0 {
+		err = applyPriority(internal.Nice)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// Signals sent from the job terminal (e.g. CTRL-C) are meant for the user
+	// command only, the wrapper has to stay alive to report the exit code.
+	// They are caught instead of being ignored, as ignoring is inherited
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGINT, syscall.SIGQUIT)
+
 	cmd := exec.Command(internal.Command, internal.Args...)
+	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	// Make sure the command doesn't outlive the wrapper,
+	// e.g. when the job is stopped by the user
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Pdeathsig: syscall.SIGKILL,
+	}
 
 	err = cmd.Run()
 	if err != nil {
@@ -1,12 +1,18 @@
 package teleworker
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/creack/pty"
 	"github.com/gofrs/uuid"
 	api "github.com/spirifoxy/teleworker/internal/api/v1"
 	ls "github.com/spirifoxy/teleworker/internal/logstreamer"
@@ -54,12 +60,104 @@ func (l *Limits) ToFlags() []string {
 	return flags
 }
 
+// Restart describes what happens when the job command exits
+type Restart struct {
+	Policy api.RestartPolicy
+	// MaxRestarts limits the number of restarts, 0 means unlimited
+	MaxRestarts int
+	// Backoff is the delay before the first restart,
+	// which is doubled after every next attempt
+	Backoff time.Duration
+}
+
+// Attempt describes one launch of the job command
+type Attempt struct {
+	ExitCode  int
+	StartedAt time.Time
+	ExitedAt  time.Time
+}
+
+// LogBudget limits the total amount of the output kept in memory
+// by all the jobs sharing it, see WithLogBudget
+type LogBudget = ls.Budget
+
+// NewLogBudget creates the budget of the given size in bytes
+func NewLogBudget(limit int64) *LogBudget {
+	return ls.NewBudget(limit)
+}
+
+// Chunk is a piece of the job output along with its position in the output
+type Chunk = ls.Chunk
+
+// StreamOptions define which part of the job output is streamed
+type StreamOptions = ls.StreamOptions
+
+// SlowStreamPolicy defines what happens to the stream which doesn't
+// keep up with the job output, see WithStreamQueue
+type SlowStreamPolicy = ls.SlowSubscriberPolicy
+
+const (
+	// DropOldest drops the oldest queued output of the stream,
+	// which is told about the number of the dropped bytes
+	DropOldest = ls.DropOldest
+	// Disconnect finishes the stream with ErrSlowStream
+	Disconnect = ls.Disconnect
+)
+
+// ErrSlowStream is carried by the last chunk of the stream
+// disconnected due to the Disconnect policy
+var ErrSlowStream = ls.ErrSlowSubscriber
+
+// LogFormat is the format of the job output lines, see WithLogFormat
+type LogFormat = ls.Format
+
+const (
+	LogText = ls.FormatText
+	LogJSON = ls.FormatJSON
+)
+
+// Predicate is the condition on the field of the JSON output line
+type Predicate = ls.Predicate
+
+// ParsePredicate parses the predicate like level=error or latency_ms>500
+func ParsePredicate(expr string) (Predicate, error) {
+	return ls.ParsePredicate(expr)
+}
+
+// LineFilter selects the lines of the job output, see FilterLines
+type LineFilter = ls.LineFilter
+
+// FilterLines streams only the lines of the job output stream selected by
+// the filter, every chunk of the resulting stream containing a single line
+func FilterLines(ctx context.Context, in <-chan Chunk, f LineFilter) <-chan Chunk {
+	return ls.FilterLines(ctx, in, f)
+}
+
+// The sources of the combined stream chunks, see Job.StreamCombined
+const (
+	SourceStdout = iota
+	SourceStderr
+)
+
 type JobState struct {
-	Status   api.JobStatus
-	ExitCode int
-	ExitErr  error
-	ExitedAt time.Time
-	Limits   *Limits
+	Status    api.JobStatus
+	ExitCode  int
+	ExitErr   error
+	CreatedAt time.Time
+	ExitedAt  time.Time
+	Limits    *Limits
+	Restart   *Restart
+	// Attempts contains all the launches of the
+	// command, the last one is the current one
+	Attempts []Attempt
+	// Nice is the priority of the job. For the running job it
+	// is the effective nice value of its process, otherwise
+	// it is the value requested on the job creation
+	Nice int
+	// LogParseErrors is the number of the output lines which are not
+	// in the job log format, LastLogParseError describes the last one
+	LogParseErrors    int64
+	LastLogParseError error
 }
 
 type Job struct {
@@ -67,15 +165,54 @@ type Job struct {
 	UserCommand string
 	UserArgs    []string
 
-	mu        sync.RWMutex
-	cmd       *exec.Cmd
+	mu  sync.RWMutex
+	cmd *exec.Cmd
+	// proc is the current attempt of the command
+	proc      process
 	outLogger *ls.LogStreamer
 	errLogger *ls.LogStreamer
-
-	User  string
-	state *JobState
-
-	done chan struct{}
+	// logOptions configure the output retention of both loggers
+	logOptions []ls.Option
+	// logDir is the directory the complete output is written to,
+	// only the latest part of it is kept in memory if it is set
+	logDir string
+	// logFormat is the format of the output lines
+	logFormat LogFormat
+	// shimDir is the directory the shims of the jobs keep their state in,
+	// the job is launched through the shim if it is set, see WithShim.
+	// The followers read the output files written by the shim
+	shimDir   string
+	followers map[string]*followReader
+
+	// stdin is the source of the data passed to the command
+	// input, stdinPipe is connected to the command on start
+	stdin     io.Reader
+	stdinPipe io.WriteCloser
+
+	// tty defines whether the command runs in a pseudo-terminal,
+	// terminal is the master side of it used by the server
+	tty      bool
+	terminal *os.File
+	// outWriter and errWriter are the ends of the output pipes passed
+	// to every attempt of the command, they are closed once the job
+	// is over. For the job with terminal both of them are its tty side
+	outWriter *os.File
+	errWriter *os.File
+
+	User string
+	// Labels are the key-value pairs the job can be selected by
+	Labels map[string]string
+	state  *JobState
+
+	// groupID is the ID of the control group the job is placed
+	// to, which is the job own ID unless the job is an exec one
+	groupID string
+
+	// stopped is closed when the user requests the job termination
+	stopped chan struct{}
+	done    chan struct{}
+	// changed is closed and replaced once the status changes
+	changed chan struct{}
 }
 
 type Option func(*Job)
@@ -92,13 +229,19 @@ func NewJob(command string, args []string, options ...Option) (*Job, error) {
 		UserArgs:    args,
 
 		state: &JobState{
-			Limits: &Limits{},
-			Status: api.JobStatus_STARTING,
+			Limits:    &Limits{},
+			Restart:   &Restart{},
+			Status:    api.JobStatus_STARTING,
+			CreatedAt: time.Now(),
 		},
 
-		done: make(chan struct{}),
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
 	}
 
+	j.groupID = j.ID.String()
+
 	// It is easy to imagine that in some cases it is required to use
 	// the library without, for example, resource limits, so some of the
 	// parameters are optional and can be omitted when configuring the server
@@ -106,24 +249,179 @@ func NewJob(command string, args []string, options ...Option) (*Job, error) {
 		opt(j)
 	}
 
-	cmd := j.selfWrapCommand()
+	if !ValidNice(j.state.Nice) {
+		return nil, fmt.Errorf("priority %d is out of range [%d, %d]", j.state.Nice, MinNice, MaxNice)
+	}
 
-	outReader, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("error setting up stdout logger: %w", err)
+	if j.tty || j.stdin != nil {
+		// The server is the one connected to the input of such jobs,
+		// so they are not going to be of much use without it anyway
+		j.shimDir = ""
+	}
+
+	switch {
+	case j.tty:
+		err = j.setupTerminal()
+	case j.shimDir != "":
+		err = j.setupShim()
+	default:
+		err = j.setupPipes()
 	}
-	errReader, err := cmd.StderrPipe()
 	if err != nil {
-		return nil, fmt.Errorf("error setting up stderr logger: %w", err)
+		return nil, err
 	}
 
-	j.cmd = cmd
-	j.outLogger = ls.NewLogStreamer(outReader)
-	j.errLogger = ls.NewLogStreamer(errReader)
+	j.cmd = j.newCommand()
+	if j.stdin != nil {
+		// The input is passed only to the first attempt
+		j.stdinPipe, err = j.cmd.StdinPipe()
+		if err != nil {
+			return nil, fmt.Errorf("error setting up stdin: %w", err)
+		}
+	}
 
 	return j, nil
 }
 
+// setupPipes connects the command output to the loggers using pipes.
+// The pipes are created manually instead of using cmd.StdoutPipe, as
+// the latter are closed on wait and the unread output would be lost
+func (j *Job) setupPipes() error {
+	outReader, outWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("error setting up stdout logger: %w", err)
+	}
+	errReader, errWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("error setting up stderr logger: %w", err)
+	}
+
+	j.outWriter = outWriter
+	j.errWriter = errWriter
+
+	return j.setupLoggers(outReader, errReader)
+}
+
+// setupTerminal connects the command to a pseudo-terminal instead of pipes.
+// The terminal merges stdout and stderr of the command, so all the output
+// goes to the stdout logger, while the stderr one always stays empty
+func (j *Job) setupTerminal() error {
+	if j.stdin != nil {
+		return fmt.Errorf("input can't be provided to the job with terminal, attach to the job instead")
+	}
+
+	terminal, tty, err := pty.Open()
+	if err != nil {
+		return fmt.Errorf("error allocating terminal: %w", err)
+	}
+
+	errReader, errWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("error setting up stderr logger: %w", err)
+	}
+	errWriter.Close()
+
+	j.terminal = terminal
+	j.outWriter = tty
+	j.errWriter = tty
+
+	return j.setupLoggers(terminal, errReader)
+}
+
+// setupLoggers starts reading the command output. If the log directory
+// is set, the complete output is written to the files in there
+func (j *Job) setupLoggers(outReader, errReader io.ReadCloser) error {
+	outOptions, errOptions := j.loggerOptions()
+
+	if j.shimDir != "" {
+		// The shim writes the complete output to the files
+		// the loggers read, so they serve as the sinks as well
+		outSink, err := ls.OpenFileSink(outputPath(j.stateDir(), shimStdout))
+		if err != nil {
+			return err
+		}
+		errSink, err := ls.OpenFileSink(outputPath(j.stateDir(), shimStderr))
+		if err != nil {
+			outSink.Remove()
+			return err
+		}
+
+		outOptions = append(outOptions[:len(outOptions):len(outOptions)], ls.WithSink(outSink))
+		errOptions = append(errOptions[:len(errOptions):len(errOptions)], ls.WithSink(errSink))
+	} else if j.logDir != "" {
+		dir := j.outputDir()
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return fmt.Errorf("error creating log directory: %w", err)
+		}
+
+		outSink, err := ls.NewFileSink(filepath.Join(dir, "stdout.log"))
+		if err != nil {
+			return err
+		}
+		errSink, err := ls.NewFileSink(filepath.Join(dir, "stderr.log"))
+		if err != nil {
+			outSink.Remove()
+			return err
+		}
+
+		outOptions = append(outOptions[:len(outOptions):len(outOptions)], ls.WithSink(outSink))
+		errOptions = append(errOptions[:len(errOptions):len(errOptions)], ls.WithSink(errSink))
+	}
+
+	j.outLogger = ls.NewLogStreamer(outReader, outOptions...)
+	j.errLogger = ls.NewLogStreamer(errReader, errOptions...)
+
+	return nil
+}
+
+// loggerOptions returns the options of both loggers. Both outputs are
+// numbered by the same sequence, so they can be streamed in the order
+// they were produced
+func (j *Job) loggerOptions() ([]ls.Option, []ls.Option) {
+	seq := ls.NewSequence()
+	outOptions := append(j.logOptions[:len(j.logOptions):len(j.logOptions)], ls.WithSequence(seq), ls.WithFormat(j.logFormat))
+	errOptions := append(j.logOptions[:len(j.logOptions):len(j.logOptions)], ls.WithSequence(seq), ls.WithFormat(j.logFormat))
+	return outOptions, errOptions
+}
+
+// outputDir returns the directory the job output is written to
+func (j *Job) outputDir() string {
+	return filepath.Join(j.logDir, j.ID.String())
+}
+
+// newCommand prepares the next attempt of the command
+func (j *Job) newCommand() *exec.Cmd {
+	cmd := j.selfWrapCommand()
+	if j.shimDir != "" {
+		// The shim writes the output to the files on its own
+		return j.shimCommand(cmd)
+	}
+	cmd.Stdout = j.outWriter
+	cmd.Stderr = j.errWriter
+
+	if j.tty {
+		cmd.Stdin = j.outWriter
+		// Make the terminal controlling for the new session,
+		// so the command gets signals like SIGINT and SIGWINCH
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Setsid:  true,
+			Setctty: true,
+		}
+	}
+
+	return cmd
+}
+
+// closeWriters closes the server copies of the command output
+// writers, so the loggers get EOF once the command is over
+func (j *Job) closeWriters() {
+	j.outWriter.Close()
+	if j.errWriter != j.outWriter {
+		j.errWriter.Close()
+	}
+}
+
 // selfWrapCommand wraps the user command in the self call.
 // Whenever user wants to start some task we launch another server process and
 // distinguish it from the main flow using flags. Then we can use that process ID
@@ -131,11 +429,14 @@ func NewJob(command string, args []string, options ...Option) (*Job, error) {
 func (j *Job) selfWrapCommand() *exec.Cmd {
 	const selfExe = "/proc/self/exe"
 
-	jobID := fmt.Sprintf("-jobid=%s", j.ID.String())
+	jobID := fmt.Sprintf("-jobid=%s", j.groupID)
 	userCommand := fmt.Sprintf("-command=%s", j.UserCommand)
 	limitFlags := j.state.Limits.ToFlags()
 
 	callArgs := append(limitFlags, jobID)
+	if j.state.Nice != 0 {
+		callArgs = append(callArgs, fmt.Sprintf("-nice=%d", j.state.Nice))
+	}
 	callArgs = append(callArgs, userCommand)
 	callArgs = append(callArgs, "--")
 	callArgs = append(callArgs, j.UserArgs...)
@@ -153,6 +454,16 @@ func WithUsername(username string) Option {
 	}
 }
 
+// WithLabels attaches the labels to the job, see HasLabels
+func WithLabels(labels map[string]string) Option {
+	return func(j *Job) {
+		j.Labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			j.Labels[k] = v
+		}
+	}
+}
+
 // WithLimits sets mem, cpu and io limits to the job.
 // If resource management is not required than set up of limits
 // might be omitted, which will result in all the tasks being
@@ -163,6 +474,125 @@ func WithLimits(limits *Limits) Option {
 	}
 }
 
+// WithRestart sets the policy of restarting the job command when it exits.
+// All the attempts write to the same output, separated by a short notice
+func WithRestart(restart *Restart) Option {
+	return func(j *Job) {
+		j.state.Restart = restart
+	}
+}
+
+// WithPriority sets the nice value the job command runs with, which is
+// applied both to cpu and io scheduling. See MinNice and MaxNice for the
+// bounds, the values out of them are rejected on the job creation
+func WithPriority(nice int) Option {
+	return func(j *Job) {
+		j.state.Nice = nice
+	}
+}
+
+// WithLogLimit limits the amount of the output kept in memory for each
+// of the job streams. Only the latest limit bytes are available for
+// streaming, the stream starts with the notice about the dropped ones.
+// Without this option the whole output is kept
+func WithLogLimit(limit int) Option {
+	return func(j *Job) {
+		j.logOptions = append(j.logOptions, ls.WithLimit(limit))
+	}
+}
+
+// WithLogCompression makes the job keep its output in memory compressed.
+// The limits apply to the memory taken by the compressed output, so much
+// more of the text output is available for streaming within them
+func WithLogCompression() Option {
+	return func(j *Job) {
+		j.logOptions = append(j.logOptions, ls.WithCompression())
+	}
+}
+
+// WithLogDir makes the job write its complete output to the files in the
+// directory, so only the latest part of it is kept in memory as set by
+// WithLogLimit. The files are removed when the job is released
+func WithLogDir(dir string) Option {
+	return func(j *Job) {
+		j.logDir = dir
+	}
+}
+
+// WithLogBudget makes the job output count towards the memory budget
+// shared with other jobs. Once it is exhausted, the jobs keep only the
+// latest output they already have space for, but not less than 64KiB
+func WithLogBudget(budget *LogBudget) Option {
+	return func(j *Job) {
+		j.logOptions = append(j.logOptions, ls.WithBudget(budget))
+	}
+}
+
+// WithLogFormat sets the format of the job output lines. The lines not in
+// the format are counted and reported by the job status, see JobState
+func WithLogFormat(format LogFormat) Option {
+	return func(j *Job) {
+		j.logFormat = format
+	}
+}
+
+// WithStreamQueue limits the live output queued for every stream of the
+// job to limit bytes. The streams which don't keep up with the output are
+// handled according to the policy, so they never slow down the job itself.
+// Without this option the queues are unlimited
+func WithStreamQueue(limit int, policy SlowStreamPolicy) Option {
+	return func(j *Job) {
+		j.logOptions = append(j.logOptions, ls.WithSubscriberQueue(limit, policy))
+	}
+}
+
+// WithStdin sets the source of the data passed to the command input.
+// The reader is consumed in the background once the job is started,
+// so it is fine to provide the data in chunks while the job is running.
+// If the reader is also a closer, it is closed when the job stops
+// accepting the input. Without this option the command gets empty input,
+// which is also the case for all the restarts of the command
+func WithStdin(r io.Reader) Option {
+	return func(j *Job) {
+		j.stdin = r
+	}
+}
+
+// WithTTY makes the job run in a pseudo-terminal, which is required
+// by interactive programs. The input for such jobs can only be
+// provided by attaching to them, see Job.WriteInput
+func WithTTY() Option {
+	return func(j *Job) {
+		j.tty = true
+	}
+}
+
+// withGroupOf places the job to the same control group
+// the parent job is in, using the same limits
+func withGroupOf(parent *Job) Option {
+	return func(j *Job) {
+		j.groupID = parent.groupID
+		j.state.Limits = parent.state.Limits
+		j.state.Nice = parent.state.Nice
+		j.logOptions = parent.logOptions
+	}
+}
+
+// LogFormat returns the format of the job output lines
+func (j *Job) LogFormat() LogFormat {
+	return j.logFormat
+}
+
+// HasLabels returns whether the job has all the labels with the same values
+func (j *Job) HasLabels(labels map[string]string) bool {
+	for k, v := range labels {
+		if value, ok := j.Labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Limited return whether any of the resource limits were
 // applied to the task upon creation
 func (j *Job) Limited() bool {
@@ -0,0 +1,57 @@
+package teleworker
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// MinNice and MaxNice are the bounds of the job priority,
+	// which follows the nice values semantics: the lower
+	// the value the higher the priority
+	MinNice = -20
+	MaxNice = 19
+
+	// ioprioClassBE is the best-effort io scheduling class,
+	// which is the default one for the processes
+	ioprioClassBE    = 2
+	ioprioClassShift = 13
+	ioprioWhoProcess = 1
+)
+
+// ValidNice checks whether the nice value is within the allowed bounds
+func ValidNice(nice int) bool {
+	return nice >= MinNice && nice <= MaxNice
+}
+
+// applyPriority sets both cpu and io scheduling priority of the current
+// process, which are inherited by the processes it starts afterwards.
+// The io priority level is derived from the nice value the same way
+// the kernel does it for the processes without explicit io priority
+func applyPriority(nice int) error {
+	err := unix.Setpriority(unix.PRIO_PROCESS, 0, nice)
+	if err != nil {
+		return fmt.Errorf("error setting nice value %d: %w", nice, err)
+	}
+
+	level := (nice + 20) / 5
+	ioprio := ioprioClassBE<<ioprioClassShift | level
+	_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(ioprio))
+	if errno != 0 {
+		return fmt.Errorf("error setting io priority level %d: %w", level, errno)
+	}
+
+	return nil
+}
+
+// niceOf returns the current nice value of the process
+func niceOf(pid int) (int, error) {
+	// The raw syscall returns the value in range 1..40
+	// to avoid negative values, same as the kernel stores it
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid)
+	if err != nil {
+		return 0, err
+	}
+	return 20 - prio, nil
+}
@@ -0,0 +1,47 @@
+package teleworker
+
+import "os/exec"
+
+// process is the running attempt of the command. It is either the direct
+// child of the server or the one supervised by the shim, see WithShim
+type process interface {
+	// Wait waits for the attempt to exit and returns its exit code along
+	// with the error describing the exit, if it was not a successful one.
+	// The exit code is -1 for the attempt killed by a signal
+	Wait() (int, error)
+	Kill() error
+	// Pid is the ID of the wrapper process running the command
+	Pid() int
+}
+
+// directProcess is the attempt started by the server itself
+type directProcess struct {
+	cmd *exec.Cmd
+}
+
+func startDirect(cmd *exec.Cmd) (*directProcess, error) {
+	err := cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &directProcess{cmd: cmd}, nil
+}
+
+func (p *directProcess) Wait() (int, error) {
+	err := p.cmd.Wait()
+
+	exitCode := p.cmd.ProcessState.ExitCode()
+	if exitCode == -1 {
+		// Killed, which is what stopping the job does
+		err = nil
+	}
+	return exitCode, err
+}
+
+func (p *directProcess) Kill() error {
+	return p.cmd.Process.Kill()
+}
+
+func (p *directProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
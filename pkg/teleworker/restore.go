@@ -0,0 +1,141 @@
+package teleworker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	ls "github.com/spirifoxy/teleworker/internal/logstreamer"
+)
+
+// ErrJobLost is the exit error of the restored job
+// which was not over when its state was saved
+var ErrJobLost = errors.New("the job was lost when the server stopped")
+
+// RestoreJob recreates the job from its state saved before the server
+// restart. If the job was not over when the state was saved and it was
+// launched through the shim, see WithShim, the job is adopted: it keeps
+// running and is tracked as usual. Otherwise the restored job is never
+// running, the one which was not over is marked stopped with ErrJobLost.
+// The output written to the files, either by the shim or to the log
+// directory, see WithLogDir, is read back from there, otherwise it is
+// empty. The options apply the same way they do for NewJob, except
+// that the job state is taken from the saved one
+func RestoreJob(id uuid.UUID, command string, args []string, state JobState, options ...Option) (*Job, error) {
+	j := &Job{
+		ID:          id,
+		UserCommand: command,
+		UserArgs:    args,
+
+		state: &JobState{},
+
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+	j.groupID = j.ID.String()
+
+	for _, opt := range options {
+		opt(j)
+	}
+
+	restored := state
+	if restored.Limits == nil {
+		restored.Limits = &Limits{}
+	}
+	if restored.Restart == nil {
+		restored.Restart = &Restart{}
+	}
+	restored.Attempts = append([]Attempt(nil), state.Attempts...)
+	j.state = &restored
+
+	over := restored.Status == api.JobStatus_FINISHED || restored.Status == api.JobStatus_STOPPED
+	if !over && j.shimDir != "" {
+		adopted, err := j.adopt()
+		if err != nil {
+			return nil, err
+		}
+		if adopted {
+			return j, nil
+		}
+	}
+
+	if !over {
+		restored.Status = api.JobStatus_STOPPED
+		restored.ExitCode = -1
+		restored.ExitErr = ErrJobLost
+		if restored.ExitedAt.IsZero() {
+			restored.ExitedAt = time.Now()
+		}
+	}
+
+	err := j.restoreLoggers()
+	if err != nil {
+		return nil, err
+	}
+
+	close(j.stopped)
+	close(j.done)
+	return j, nil
+}
+
+// restoreLoggers reads the output saved to the files. The loggers use the
+// same files as the sinks, so the complete output is available as before
+func (j *Job) restoreLoggers() error {
+	outOptions, errOptions := j.loggerOptions()
+
+	outReader, outOptions, err := j.savedOutput("stdout.log", outOptions)
+	if err != nil {
+		return err
+	}
+	errReader, errOptions, err := j.savedOutput("stderr.log", errOptions)
+	if err != nil {
+		outReader.Close()
+		return err
+	}
+
+	j.outLogger = ls.NewLogStreamer(outReader, outOptions...)
+	j.errLogger = ls.NewLogStreamer(errReader, errOptions...)
+	return nil
+}
+
+// savedOutput opens the output saved before the restart along with the
+// sink over it, the output is empty if there is no such file
+func (j *Job) savedOutput(name string, options []ls.Option) (io.ReadCloser, []ls.Option, error) {
+	// The jobs with terminal or input are launched directly even if
+	// the shim is used, so their output is in the log directory
+	var dirs []string
+	if j.shimDir != "" {
+		dirs = append(dirs, j.stateDir())
+	}
+	if j.logDir != "" {
+		dirs = append(dirs, j.outputDir())
+	}
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening saved output: %w", err)
+		}
+
+		sink, err := ls.OpenFileSink(path)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return file, append(options[:len(options):len(options)], ls.WithSink(sink)), nil
+	}
+
+	return ioutil.NopCloser(strings.NewReader("")), options, nil
+}
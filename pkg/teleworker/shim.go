@@ -0,0 +1,587 @@
+package teleworker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+)
+
+const (
+	// shimFlag marks the self call running the shim, see runShim
+	shimFlag = "-shim="
+	// shimSocket is the socket the shim is controlled through
+	shimSocket = "shim.sock"
+	// shimExit is the file the shim saves the exit code of the command to
+	shimExit = "exit"
+
+	// shimStdout and shimStderr name the outputs of the command, the
+	// shim writes them to the files with the same name in its directory
+	shimStdout = "stdout"
+	shimStderr = "stderr"
+
+	// shimStartTimeout limits waiting for the started shim to listen
+	shimStartTimeout  = 5 * time.Second
+	shimRetryInterval = 10 * time.Millisecond
+	// shimCopySize is the size of the pieces the output is copied by
+	shimCopySize = 32 * 1024
+)
+
+// WithShim makes the job command run under the supervision of a separate
+// shim process instead of being the direct child of the server, similarly
+// to containerd-shim. The shim keeps its state in the dir/<job id>: it
+// writes the output to the files in there, saves the exit code, tells the
+// server about the new output and accepts the requests to kill the command
+// through the socket. The shim doesn't depend on the server, so the job
+// keeps running when the server stops and is adopted back once it is
+// restored, see RestoreJob. The complete output of such jobs is always
+// kept in the files, so WithLogDir makes no difference for them. The jobs
+// with terminal or input are launched directly regardless of this option
+func WithShim(dir string) Option {
+	return func(j *Job) {
+		j.shimDir = dir
+	}
+}
+
+// stateDir returns the directory the shim of the job keeps its state in
+func (j *Job) stateDir() string {
+	return filepath.Join(j.shimDir, j.ID.String())
+}
+
+// setupShim prepares the files the shim writes the output to.
+// The loggers keep reading them until the job is over
+func (j *Job) setupShim() error {
+	dir := j.stateDir()
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("error creating shim directory: %w", err)
+	}
+
+	// The server itself writes only the restart notices
+	outWriter, err := openOutput(outputPath(dir, shimStdout))
+	if err != nil {
+		return err
+	}
+	errWriter, err := openOutput(outputPath(dir, shimStderr))
+	if err != nil {
+		outWriter.Close()
+		return err
+	}
+	j.outWriter = outWriter
+	j.errWriter = errWriter
+
+	outReader, err := followOutput(outputPath(dir, shimStdout), j.done)
+	if err != nil {
+		return err
+	}
+	errReader, err := followOutput(outputPath(dir, shimStderr), j.done)
+	if err != nil {
+		outReader.Close()
+		return err
+	}
+	j.followers = map[string]*followReader{
+		shimStdout: outReader,
+		shimStderr: errReader,
+	}
+
+	return j.setupLoggers(outReader, errReader)
+}
+
+// outputPath returns the path of the file the output is written to
+func outputPath(dir, name string) string {
+	return filepath.Join(dir, name+".log")
+}
+
+func openOutput(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening output file: %w", err)
+	}
+	return file, nil
+}
+
+// shimCommand wraps the self call running the command into the shim
+func (j *Job) shimCommand(wrapper *exec.Cmd) *exec.Cmd {
+	args := append([]string{shimFlag + j.stateDir()}, wrapper.Args[1:]...)
+
+	cmd := exec.Command(wrapper.Path, args...)
+	// The shim doesn't belong to the session of the server,
+	// so it is not interrupted along with the server
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+	return cmd
+}
+
+// startShim launches the prepared shim. It might take the shim a while to
+// listen, so it is connected in the background rather than holding up the
+// caller, who holds the locks of the job and maybe of the queue as well.
+// Should be called holding the lock
+func (j *Job) startShim() (process, error) {
+	// The exit code left by the previous attempt
+	// must not be taken for the one of the new attempt
+	err := os.Remove(filepath.Join(j.stateDir(), shimExit))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error preparing shim: %w", err)
+	}
+
+	cmd := j.cmd
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	// The shim is the child of the server as long as
+	// the server is running, so it has to be reaped
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	p := newShimProcess(j.stateDir(), j.followers)
+	go func() {
+		err := p.dial(shimStartTimeout, exited)
+		if err != nil {
+			cmd.Process.Kill()
+		}
+	}()
+	return p, nil
+}
+
+// adopt connects to the shim of the job which was running before the
+// server restart and keeps tracking the job as if it was started by this
+// server. The job which finished meanwhile is tracked the same way, so its
+// restart policy is applied as usual. Returns false if there is no shim
+func (j *Job) adopt() (bool, error) {
+	p := newShimProcess(j.stateDir(), nil)
+	err := p.dial(0, nil)
+	if err != nil {
+		return false, nil
+	}
+
+	err = j.setupShim()
+	if err != nil {
+		return false, err
+	}
+
+	p.followers = j.followers
+	j.proc = p
+	j.state.Status = api.JobStatus_ALIVE
+	if len(j.state.Attempts) == 0 {
+		j.state.Attempts = append(j.state.Attempts, Attempt{
+			StartedAt: j.state.CreatedAt,
+		})
+	}
+
+	go j.run()
+	return true, nil
+}
+
+// shimProcess is the attempt supervised by the shim
+type shimProcess struct {
+	dir string
+	// followers are woken up once the shim writes more of their output
+	followers map[string]*followReader
+	// connected is closed once the shim is connected,
+	// err is set if it failed to connect
+	connected chan struct{}
+	err       error
+
+	// mu guards the connection and the process ID
+	mu sync.Mutex
+	// conn is nil if the command was over before the connection
+	conn   net.Conn
+	reader *bufio.Reader
+	pid    int
+	// killed is set if the command was killed before the connection
+	killed bool
+}
+
+func newShimProcess(dir string, followers map[string]*followReader) *shimProcess {
+	return &shimProcess{
+		dir:       dir,
+		followers: followers,
+		connected: make(chan struct{}),
+	}
+}
+
+// dial connects to the shim keeping its state in the directory.
+// The shim might not be listening yet, so the connection is retried
+// until the timeout or until the shim is exited
+func (p *shimProcess) dial(timeout time.Duration, exited <-chan struct{}) error {
+	defer close(p.connected)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.Dial("unix", filepath.Join(p.dir, shimSocket))
+		if err == nil {
+			p.err = p.connect(conn)
+			return p.err
+		}
+
+		if _, ok := readExit(p.dir); ok {
+			// The command was over before the connection
+			return nil
+		}
+
+		select {
+		case <-exited:
+			p.err = fmt.Errorf("shim exited without running the command")
+			return p.err
+		default:
+		}
+
+		if !time.Now().Before(deadline) {
+			p.err = fmt.Errorf("error connecting to the shim: %w", err)
+			return p.err
+		}
+		time.Sleep(shimRetryInterval)
+	}
+}
+
+// connect receives the greeting of the shim carrying the process ID
+func (p *shimProcess) connect(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+
+	var pid int
+	conn.SetReadDeadline(time.Now().Add(shimStartTimeout))
+	line, err := reader.ReadString('\n')
+	if err == nil {
+		_, err = fmt.Sscanf(line, "pid %d", &pid)
+	}
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error connecting to the shim: %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.conn = conn
+	p.reader = reader
+	p.pid = pid
+	if p.killed {
+		// The shim notices the failure to deliver it by itself
+		io.WriteString(conn, "kill\n")
+	}
+	return nil
+}
+
+func (p *shimProcess) Wait() (int, error) {
+	<-p.connected
+	if p.err != nil {
+		return -1, p.err
+	}
+
+	if p.conn != nil {
+		defer p.conn.Close()
+
+		// Nobody was told about the output written before the connection
+		for _, r := range p.followers {
+			r.wake()
+		}
+
+		for {
+			line, err := p.reader.ReadString('\n')
+			if err != nil {
+				// The shim is gone, but it might
+				// have saved the exit code before
+				break
+			}
+
+			var name string
+			_, err = fmt.Sscanf(line, "output %s", &name)
+			if err == nil {
+				if r, ok := p.followers[name]; ok {
+					r.wake()
+				}
+				continue
+			}
+
+			var exitCode int
+			_, err = fmt.Sscanf(line, "exit %d", &exitCode)
+			if err == nil {
+				return exitResult(exitCode)
+			}
+		}
+	}
+
+	exitCode, ok := readExit(p.dir)
+	if !ok {
+		return -1, ErrJobLost
+	}
+	return exitResult(exitCode)
+}
+
+func (p *shimProcess) Kill() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		select {
+		case <-p.connected:
+			// The command is over
+		default:
+			// The command is killed once the shim is connected
+			p.killed = true
+		}
+		return nil
+	}
+
+	_, err := io.WriteString(p.conn, "kill\n")
+	return err
+}
+
+func (p *shimProcess) Pid() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pid
+}
+
+// exitResult describes the exit code the same way exec.ExitError does
+func exitResult(exitCode int) (int, error) {
+	if exitCode > 0 {
+		return exitCode, fmt.Errorf("exit status %d", exitCode)
+	}
+	return exitCode, nil
+}
+
+// readExit returns the exit code saved by the shim, if there is one
+func readExit(dir string) (int, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, shimExit))
+	if err != nil {
+		return 0, false
+	}
+	exitCode, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return exitCode, true
+}
+
+// runShim runs the wrapper of the command with the arguments, copying its
+// output to the files in the directory, and serves the requests of the
+// server until the command is over. Every connection is told about the new
+// output once it is written. The exit code is saved to the file, so it is
+// available even if the server is not connected at the moment
+func runShim(dir string, args []string) {
+	outFile, err := openOutput(outputPath(dir, shimStdout))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	errFile, err := openOutput(outputPath(dir, shimStderr))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	socket := filepath.Join(dir, shimSocket)
+	os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var mu sync.Mutex
+	conns := make(map[net.Conn]struct{})
+	// announce sends the line to every connection, dropping the broken ones
+	announce := func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for conn := range conns {
+			_, err := io.WriteString(conn, line)
+			if err != nil {
+				conn.Close()
+				delete(conns, conn)
+			}
+		}
+	}
+
+	outReader, outWriter, err := os.Pipe()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	errReader, errWriter, err := os.Pipe()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	cmd := exec.Command("/proc/self/exe", args...)
+	cmd.Stdout = outWriter
+	cmd.Stderr = errWriter
+	err = cmd.Start()
+	if err != nil {
+		listener.Close()
+		log.Fatalln(err)
+	}
+	// The command keeps its own ends of the pipes, so the
+	// output is over once the command and its children exit
+	outWriter.Close()
+	errWriter.Close()
+
+	var copying sync.WaitGroup
+	copying.Add(2)
+	go func() {
+		defer copying.Done()
+		copyOutput(outFile, outReader, func() { announce("output " + shimStdout + "\n") })
+	}()
+	go func() {
+		defer copying.Done()
+		copyOutput(errFile, errReader, func() { announce("output " + shimStderr + "\n") })
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			conns[conn] = struct{}{}
+			fmt.Fprintf(conn, "pid %d\n", cmd.Process.Pid)
+			mu.Unlock()
+
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if scanner.Text() == "kill" {
+						cmd.Process.Kill()
+					}
+				}
+			}()
+		}
+	}()
+
+	cmd.Wait()
+	exitCode := cmd.ProcessState.ExitCode()
+	copying.Wait()
+
+	// The exit code is saved before anybody is told about it,
+	// so the server connecting later doesn't miss it either
+	err = writeExit(dir, exitCode)
+	if err != nil {
+		log.Println(err)
+	}
+	listener.Close()
+
+	mu.Lock()
+	for conn := range conns {
+		fmt.Fprintf(conn, "exit %d\n", exitCode)
+		conn.Close()
+	}
+	mu.Unlock()
+
+	os.Exit(0)
+}
+
+// copyOutput copies the output to the file until the output
+// is over, calling written after every piece of it
+func copyOutput(file *os.File, output io.Reader, written func()) {
+	buf := make([]byte, shimCopySize)
+	for {
+		n, err := output.Read(buf)
+		if n > 0 {
+			// The output has to be read anyway,
+			// otherwise the command gets stuck
+			_, writeErr := file.Write(buf[:n])
+			if writeErr != nil {
+				log.Println(writeErr)
+			}
+			written()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeExit saves the exit code, replacing the file atomically
+func writeExit(dir string, exitCode int) error {
+	tmp, err := ioutil.TempFile(dir, shimExit+".")
+	if err != nil {
+		return fmt.Errorf("error saving exit code: %w", err)
+	}
+	_, err = fmt.Fprintf(tmp, "%d\n", exitCode)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, shimExit))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error saving exit code: %w", err)
+	}
+	return nil
+}
+
+// followReader reads the file written by the shim, waiting for more
+// data at its end until the job is over. The reader is woken up
+// once the shim tells there is more data, see shimProcess.Wait
+type followReader struct {
+	file *os.File
+	done <-chan struct{}
+	more chan struct{}
+}
+
+func followOutput(path string, done <-chan struct{}) (*followReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening output file: %w", err)
+	}
+	return &followReader{
+		file: file,
+		done: done,
+		more: make(chan struct{}, 1),
+	}, nil
+}
+
+// wake lets the reader know there is more data in the file. The reader
+// checks the file once again anyway, so a single pending wakeup is enough
+func (r *followReader) wake() {
+	select {
+	case r.more <- struct{}{}:
+	default:
+	}
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.file.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		select {
+		case <-r.done:
+			// The output written right before the
+			// job was over might not have been read yet
+			n, err = r.file.Read(p)
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		case <-r.more:
+		}
+	}
+}
+
+func (r *followReader) Close() error {
+	return r.file.Close()
+}
@@ -3,9 +3,15 @@ package teleworker
 import (
 	"context"
 	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/creack/pty"
 	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	ls "github.com/spirifoxy/teleworker/internal/logstreamer"
 	cg "github.com/spirifoxy/teleworker/pkg/cgroup"
 )
 
@@ -13,50 +19,280 @@ func (j *Job) Start() error {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	if j.state.Status != api.JobStatus_STARTING {
+	status := j.state.Status
+	if status != api.JobStatus_STARTING && status != api.JobStatus_QUEUED {
 		return fmt.Errorf("not possible to start the job: unexpected status %s on start", j.state.Status.String())
 	}
 
-	err := j.cmd.Start()
+	err := j.startAttempt()
 	if err != nil {
-		return fmt.Errorf("error starting the task: %w", err)
+		err = fmt.Errorf("error starting the task: %w", err)
+		// The job might have been queued, so somebody
+		// might be already waiting for it to be over
+		j.finish(api.JobStatus_FINISHED, err)
+		return err
+	}
+
+	if j.stdinPipe != nil {
+		go j.feedStdin()
+	}
+	go j.run()
+	return nil
+}
+
+// Enqueue marks the job as waiting for a free slot to be started.
+// The queued job is started as usual with Start, or it can be
+// cancelled with Stop before that
+func (j *Job) Enqueue() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.state.Status != api.JobStatus_STARTING {
+		return fmt.Errorf("not possible to queue the job: unexpected status %s", j.state.Status.String())
 	}
-	j.state.Status = api.JobStatus_ALIVE
 
-	go j.wait()
+	j.setStatus(api.JobStatus_QUEUED)
 	return nil
 }
 
-func (j *Job) wait() {
-	err := j.cmd.Wait()
+// finish ends the job which has never been started, releasing everything
+// prepared for it. Should be called holding the lock
+func (j *Job) finish(status api.JobStatus, err error) {
+	j.setStatus(status)
+	j.state.ExitCode = -1
+	j.state.ExitErr = err
+	j.state.ExitedAt = time.Now()
+
+	j.closeWriters()
+	if j.stdinPipe != nil {
+		j.stdinPipe.Close()
+	}
+	if closer, ok := j.stdin.(io.Closer); ok {
+		closer.Close()
+	}
+	close(j.done)
+}
+
+// startAttempt launches the prepared command and registers a new attempt.
+// Should be called holding the lock
+func (j *Job) startAttempt() error {
+	var err error
+	if j.shimDir != "" {
+		j.proc, err = j.startShim()
+	} else {
+		j.proc, err = startDirect(j.cmd)
+	}
+	if err != nil {
+		return err
+	}
 
+	j.setStatus(api.JobStatus_ALIVE)
+	j.state.Attempts = append(j.state.Attempts, Attempt{
+		StartedAt: time.Now(),
+	})
+	return nil
+}
+
+// feedStdin passes the job input to the command until either the
+// input is over or the command is not able to accept it anymore
+func (j *Job) feedStdin() {
+	_, err := io.Copy(j.stdinPipe, j.stdin)
+	if err != nil {
+		// Most likely the command has finished without reading
+		// all the input, which is a completely valid scenario
+		log.Printf("job %s stopped accepting input: %v", j.ID, err)
+	}
+	j.stdinPipe.Close()
+
+	// Let the input provider know that nobody is reading anymore,
+	// otherwise it might get stuck writing the rest of the data
+	if closer, ok := j.stdin.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// run waits for the command to exit and restarts
+// it until the restart policy allows to do so
+func (j *Job) run() {
+	defer close(j.done)
+	// Nothing is going to be written anymore, so
+	// the loggers can finish once the output is read
+	defer j.closeWriters()
+	defer j.markStopped()
+
+	for {
+		exitCode := j.wait()
+
+		delay, restart := j.nextRestart(exitCode)
+		if !restart {
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-j.stopped:
+			return
+		}
+
+		restarted, err := j.restart(exitCode)
+		if err != nil {
+			log.Printf("job %s failed to restart: %v", j.ID, err)
+		}
+		if !restarted {
+			return
+		}
+	}
+}
+
+// markStopped sets the status of the job stopped by the user
+// before anybody waiting for the job learns it is over
+func (j *Job) markStopped() {
 	j.mu.Lock()
-	exitCode := j.cmd.ProcessState.ExitCode()
-	if err != nil && exitCode != -1 {
-		j.state.ExitErr = err
+	defer j.mu.Unlock()
+
+	select {
+	case <-j.stopped:
+		j.setStatus(api.JobStatus_STOPPED)
+	default:
 	}
+}
+
+// wait waits for the current attempt to exit and returns its exit code
+func (j *Job) wait() int {
+	j.mu.RLock()
+	proc := j.proc
+	j.mu.RUnlock()
+
+	exitCode, err := proc.Wait()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.state.ExitErr = err
 
 	j.state.ExitCode = exitCode
-	j.state.Status = api.JobStatus_FINISHED
-	j.mu.Unlock()
+	j.setStatus(api.JobStatus_FINISHED)
+	j.state.ExitedAt = time.Now()
 
-	j.outLogger.Close()
-	j.errLogger.Close()
-	close(j.done)
+	attempt := &j.state.Attempts[len(j.state.Attempts)-1]
+	attempt.ExitCode = exitCode
+	attempt.ExitedAt = j.state.ExitedAt
+
+	return exitCode
+}
+
+// nextRestart decides whether the command has to be restarted after it exited
+// with the given code, and if so - how long to wait before restarting
+func (j *Job) nextRestart(exitCode int) (time.Duration, bool) {
+	const (
+		defaultBackoff = time.Second
+		maxBackoff     = 5 * time.Minute
+	)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	select {
+	case <-j.stopped:
+		return 0, false
+	default:
+	}
+
+	r := j.state.Restart
+	switch r.Policy {
+	case api.RestartPolicy_ALWAYS:
+	case api.RestartPolicy_ON_FAILURE:
+		if exitCode == 0 {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	restarts := len(j.state.Attempts) - 1
+	if r.MaxRestarts > 0 && restarts >= r.MaxRestarts {
+		return 0, false
+	}
+
+	delay := r.Backoff
+	if delay <= 0 {
+		delay = defaultBackoff
+	}
+	for i := 0; i < restarts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	j.setStatus(api.JobStatus_RESTARTING)
+	return delay, true
+}
+
+// restart launches the next attempt of the command, letting the output
+// readers know where the new attempt begins. Returns whether it was launched
+func (j *Job) restart(exitCode int) (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	select {
+	case <-j.stopped:
+		// The job was stopped while the lock was released
+		return false, nil
+	default:
+	}
+
+	notice := fmt.Sprintf("--- attempt %d, the previous one exited with code %d ---\n", len(j.state.Attempts)+1, exitCode)
+	if j.tty {
+		notice = strings.ReplaceAll(notice, "\n", "\r\n")
+	}
+	j.outWriter.WriteString(notice)
+	if j.errWriter != j.outWriter {
+		j.errWriter.WriteString(notice)
+	}
+
+	j.cmd = j.newCommand()
+	err := j.startAttempt()
+	if err != nil {
+		j.setStatus(api.JobStatus_FINISHED)
+		return false, err
+	}
+
+	return true, nil
 }
 
 func (j *Job) Stop() error {
 	j.mu.Lock()
-	if j.state.Status != api.JobStatus_ALIVE {
+	status := j.state.Status
+	if status == api.JobStatus_QUEUED {
+		// Nothing is running yet, so the job is just not going to be started
+		j.finish(api.JobStatus_STOPPED, nil)
+		j.mu.Unlock()
+		return nil
+	}
+	if status != api.JobStatus_ALIVE && status != api.JobStatus_RESTARTING {
 		j.mu.Unlock()
 		return fmt.Errorf("not possible to stop the job as it's not alive; please check the status")
 	}
 
-	err := j.cmd.Process.Kill()
-	if err != nil {
+	select {
+	case <-j.stopped:
 		j.mu.Unlock()
-		return fmt.Errorf("not possible to stop the task: %w", err)
+		return fmt.Errorf("not possible to stop the job as it's already being stopped")
+	default:
+	}
+
+	// The job might be waiting for restart, so there is nothing to kill
+	killing := status == api.JobStatus_ALIVE
+	if killing {
+		err := j.proc.Kill()
+		if err != nil {
+			j.mu.Unlock()
+			return fmt.Errorf("not possible to stop the task: %w", err)
+		}
 	}
+	// Make sure the command is not restarted anymore
+	close(j.stopped)
 	j.mu.Unlock() // Unlock here in order not to lock forever in the wait call
 
 	// Wait for the goroutine launched upon the task creation to finish.
@@ -66,10 +302,10 @@ func (j *Job) Stop() error {
 		j.mu.Lock()
 		defer j.mu.Unlock()
 
-		j.state.Status = api.JobStatus_STOPPED
+		j.setStatus(api.JobStatus_STOPPED)
 		j.state.ExitedAt = time.Now()
 
-		if j.state.ExitErr != nil {
+		if killing && j.state.ExitErr != nil {
 			return fmt.Errorf("error while trying to stop the task: %w", j.state.ExitErr)
 		}
 
@@ -91,6 +327,11 @@ func (j *Job) tryRemovingCgroup() error {
 	if !j.Limited() {
 		return nil
 	}
+	if j.groupID != j.ID.String() {
+		// The group belongs to the job the command was executed in,
+		// which might still be running, so it removes the group itself
+		return nil
+	}
 
 	cgroup := cg.NewV1Service()
 	var err error
@@ -103,7 +344,7 @@ func (j *Job) tryRemovingCgroup() error {
 	for {
 		select {
 		case <-ticker.C:
-			err = cgroup.Remove(j.ID.String())
+			err = cgroup.Remove(j.groupID)
 			if err == nil {
 				return err
 			}
@@ -113,25 +354,183 @@ func (j *Job) tryRemovingCgroup() error {
 	}
 }
 
+// Exec runs another command in the same resource context the job runs
+// in, which is handy for inspecting a stuck job. The job has no namespaces
+// of its own, so only the control group is shared. The command is wrapped
+// into a separate job, which is returned already started
+func (j *Job) Exec(command string, args []string) (*Job, error) {
+	j.mu.RLock()
+	active := j.Active()
+	j.mu.RUnlock()
+
+	if !active {
+		return nil, fmt.Errorf("not possible to exec in the job as it's not alive; please check the status")
+	}
+
+	e, err := NewJob(
+		command,
+		args,
+		WithUsername(j.User),
+		withGroupOf(j),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Done returns a channel that is closed when the job is over
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Changed returns a channel that is closed once the status of the job
+// changes. Every change closes the channel returned before it, so it
+// has to be requested again to learn about the next change
+func (j *Job) Changed() <-chan struct{} {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.changed
+}
+
+// setStatus changes the status of the job and lets everybody waiting
+// for the change know about it. Should be called holding the lock
+func (j *Job) setStatus(status api.JobStatus) {
+	j.state.Status = status
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// Status returns the copy of the job state, so it is
+// safe to use it while the job keeps running
 func (j *Job) Status() *JobState {
 	j.mu.RLock()
 	defer j.mu.RUnlock()
 
-	return j.state
+	state := *j.state
+	state.Attempts = make([]Attempt, len(j.state.Attempts))
+	copy(state.Attempts, j.state.Attempts)
+
+	if j.Active() {
+		// The priority might have been changed from the outside. The process
+		// ID is not known until the shim is connected, and zero would refer
+		// to the server itself
+		if pid := j.proc.Pid(); pid > 0 {
+			nice, err := niceOf(pid)
+			if err == nil {
+				state.Nice = nice
+			}
+		}
+	}
+
+	for _, logger := range []*ls.LogStreamer{j.outLogger, j.errLogger} {
+		if logger == nil {
+			// The job is not started yet
+			continue
+		}
+		count, last := logger.ParseErrors()
+		state.LogParseErrors += count
+		if last != nil {
+			state.LastLogParseError = last
+		}
+	}
+
+	return &state
+}
+
+func (j *Job) StreamStdout(opts StreamOptions) (<-chan Chunk, context.CancelFunc) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	ctx, streamCancel := context.WithCancel(context.Background())
+	return j.outLogger.Stream(ctx, opts), streamCancel
+}
+
+func (j *Job) StreamStderr(opts StreamOptions) (<-chan Chunk, context.CancelFunc) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	ctx, streamCancel := context.WithCancel(context.Background())
+	return j.errLogger.Stream(ctx, opts), streamCancel
 }
 
-func (j *Job) StreamStdout() (<-chan []byte, context.CancelFunc) {
+// StreamCombined streams both stdout and stderr of the job in the order
+// they were produced. The chunks carry the source they come from,
+// the options apply to both outputs separately
+func (j *Job) StreamCombined(opts StreamOptions) (<-chan Chunk, context.CancelFunc) {
 	j.mu.RLock()
 	defer j.mu.RUnlock()
 
 	ctx, streamCancel := context.WithCancel(context.Background())
-	return j.outLogger.Stream(j.Active(), ctx), streamCancel
+	return ls.Merge(ctx, j.outLogger.Stream(ctx, opts), j.errLogger.Stream(ctx, opts)), streamCancel
 }
 
-func (j *Job) StreamStderr() (<-chan []byte, context.CancelFunc) {
+// Release frees the resources taken by the job output, including the
+// files in the log directory, so the output is not available for
+// streaming afterwards. It is meant to be called when the finished
+// job is not needed anymore, e.g. removed from the storage
+func (j *Job) Release() {
+	j.outLogger.Release()
+	j.errLogger.Release()
+
+	if j.shimDir != "" {
+		// The shim might have left its state next to the output
+		err := os.RemoveAll(j.stateDir())
+		if err != nil {
+			log.Printf("error removing job %s shim directory: %v", j.ID, err)
+		}
+	} else if j.logDir != "" {
+		err := os.Remove(j.outputDir())
+		if err != nil {
+			log.Printf("error removing job %s output directory: %v", j.ID, err)
+		}
+	}
+}
+
+// Interactive returns whether the job runs in a terminal,
+// meaning that it is possible to attach to it
+func (j *Job) Interactive() bool {
+	return j.tty
+}
+
+// Attach starts the stream of the job terminal output. Unlike the
+// other streams, only the output produced since the moment
+// of attaching is sent, similarly to the real terminal
+func (j *Job) Attach() (<-chan Chunk, context.CancelFunc, error) {
 	j.mu.RLock()
 	defer j.mu.RUnlock()
 
+	if !j.tty {
+		return nil, nil, fmt.Errorf("not possible to attach to the job as it was started without terminal")
+	}
+
 	ctx, streamCancel := context.WithCancel(context.Background())
-	return j.errLogger.Stream(j.Active(), ctx), streamCancel
+	return j.outLogger.Follow(ctx), streamCancel, nil
+}
+
+// WriteInput passes the data to the job terminal as if it was typed by the user
+func (j *Job) WriteInput(p []byte) (int, error) {
+	if !j.tty {
+		return 0, fmt.Errorf("not possible to write to the job as it was started without terminal")
+	}
+
+	return j.terminal.Write(p)
+}
+
+// Resize changes the size of the job terminal window
+func (j *Job) Resize(rows, cols uint16) error {
+	if !j.tty {
+		return fmt.Errorf("not possible to resize the terminal as the job was started without it")
+	}
+
+	return pty.Setsize(j.terminal, &pty.Winsize{
+		Rows: rows,
+		Cols: cols,
+	})
 }
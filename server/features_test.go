@@ -1,21 +1,38 @@
 package main
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/cucumber/godog"
 	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
 	"github.com/spirifoxy/teleworker/server/internal/auth"
+	"github.com/spirifoxy/teleworker/server/internal/queue"
+	"github.com/spirifoxy/teleworker/server/internal/scheduler"
 	"github.com/spirifoxy/teleworker/server/internal/storage"
+	"github.com/spirifoxy/teleworker/server/internal/workflow"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // assertExpectedAndActual is a helper function to allow the step function to call
@@ -54,7 +71,10 @@ func initSuite() {
 	listener = bufconn.Listen(bufSize)
 	grpcServer := grpc.NewServer()
 	twServer := &TWServer{
-		store: storage.NewMemStorage(),
+		store:     storage.NewMemStorage(),
+		workflows: workflow.NewMemStorage(),
+		scheduler: scheduler.NewScheduler(),
+		queue:     queue.New(),
 	}
 
 	api.RegisterTeleWorkerServer(grpcServer, twServer)
@@ -70,6 +90,11 @@ func initSuite() {
 // which might be useful for debugging or running all the tests
 // with only one command
 func TestMain(m *testing.M) {
+	// Jobs are launched by calling the test binary itself,
+	// so it has to behave the same way the server does
+	tw.InternalCallHandle()
+	initAuthServer()
+
 	opts := godog.Options{
 		Format:    "pretty",
 		Paths:     []string{"test/features"},
@@ -94,6 +119,23 @@ type State struct {
 	ctx       context.Context
 	command   string
 	arguments []string
+	input     string
+	tty       bool
+	restart   *api.StartRequest
+	priority  int32
+	logFormat api.LogFormat
+	// stream configures the next stream request
+	stream *api.StreamRequest
+	// compressed makes the stream request use the compression
+	compressed bool
+	// scheduleID is the ID of the last created schedule
+	scheduleID string
+	// listRequest is the last request listing the jobs
+	listRequest *api.ListJobsRequest
+	// headerJobID is the job ID sent back before the input was streamed
+	headerJobID string
+	// attach is the open session of the job terminal
+	attach *attachSession
 
 	lastError error
 	subject   interface{}
@@ -136,11 +178,20 @@ func InitializeScenario(ctx *godog.ScenarioContext) {
 	ctx.Step(`^the response is error$`, theResponseIsError)
 	ctx.Step(`^the job was created$`, theJobWasCreated)
 	ctx.Step(`^I wait for a second$`, iWaitForASecond)
+	ctx.Step(`^I wait for the job to be over$`, iWaitForTheJobToBeOver)
 
 	// start
 	ctx.Step(`^I pass my command (.*)$`, iPassMyCommand)
 	ctx.Step(`^I pass command argument (.*)$`, iPassCommandArgument)
+	ctx.Step(`^I pass command input (.*)$`, iPassCommandInput)
+	ctx.Step(`^I pass restart policy (.*) with (\d+) restarts$`, iPassRestartPolicy)
+	ctx.Step(`^I pass priority (-?\d+)$`, iPassPriority)
+	ctx.Step(`^I pass log format json$`, iPassLogFormatJSON)
+	ctx.Step(`^I see the job has (\d+) log parse errors?$`, iSeeTheJobHasLogParseErrors)
 	ctx.Step(`^I try to create new job$`, iTryToCreateNewJob)
+	ctx.Step(`^I try to create new job streaming the input$`, iTryToCreateNewJobStreamingTheInput)
+	ctx.Step(`^I got the job uuid before the input was sent$`, iGotTheJobUuidBeforeTheInputWasSent)
+	ctx.Step(`^I see the job output is exactly the input$`, iSeeTheJobOutputIsExactlyTheInput)
 	ctx.Step(`^I get the job uuid$`, iGetTheJobUuid)
 
 	// stop
@@ -151,6 +202,85 @@ func InitializeScenario(ctx *godog.ScenarioContext) {
 	ctx.Step(`^I see the job is finished$`, iSeeTheJobIsFinished)
 	ctx.Step(`^I see the job is still running$`, iSeeTheJobIsStillRunning)
 	ctx.Step(`^I try to get status of the job$`, iTryToGetStatusOfTheJob)
+	ctx.Step(`^I see the job attempt is (\d+)$`, iSeeTheJobAttemptIs)
+	ctx.Step(`^I see the job nice value is (-?\d+)$`, iSeeTheJobNiceValueIs)
+
+	// exec
+	ctx.Step(`^I try to exec (.*) in the job$`, iTryToExecInTheJob)
+	ctx.Step(`^I try to exec (.*) in some random job$`, iTryToExecInSomeRandomJob)
+	ctx.Step(`^I see the exec output is (.*)$`, iSeeTheExecOutputIs)
+	ctx.Step(`^I see the exec exit code is (\d+)$`, iSeeTheExecExitCodeIs)
+
+	// workflow
+	ctx.Step(`^I submit the workflow$`, iSubmitTheWorkflow)
+	ctx.Step(`^I try to get status of the workflow$`, iTryToGetStatusOfTheWorkflow)
+	ctx.Step(`^I wait for the workflow to be over$`, iWaitForTheWorkflowToBeOver)
+	ctx.Step(`^I see the workflow is (\w+)$`, iSeeTheWorkflowIs)
+	ctx.Step(`^I see the step (\w+) is (\w+)$`, iSeeTheStepIs)
+
+	// stream
+	ctx.Step(`^I stream from offset (\d+)$`, iStreamFromOffset)
+	ctx.Step(`^I stream the last (\d+) lines$`, iStreamTheLastLines)
+	ctx.Step(`^I stream following the output$`, iStreamFollowingTheOutput)
+	ctx.Step(`^I stream without setting the follow flag$`, iStreamWithoutSettingTheFollowFlag)
+	ctx.Step(`^I try to stream the job output$`, iTryToStreamTheJobOutput)
+	ctx.Step(`^I see the streamed output is (.*)$`, iSeeTheStreamedOutputIs)
+	ctx.Step(`^I see the streamed output starts at offset (\d+)$`, iSeeTheStreamedOutputStartsAtOffset)
+	ctx.Step(`^I stream both outputs$`, iStreamBothOutputs)
+	ctx.Step(`^I stream with compression$`, iStreamWithCompression)
+	ctx.Step(`^I stream the lines where (.*)$`, iStreamTheLinesWhere)
+	ctx.Step(`^I stream only the fields (.*)$`, iStreamOnlyTheFields)
+	ctx.Step(`^I stream the lines matching (.*)$`, iStreamTheLinesMatching)
+	ctx.Step(`^I stream the lines not matching (.*)$`, iStreamTheLinesNotMatching)
+	ctx.Step(`^I stream (\d+) lines around them$`, iStreamLinesAroundThem)
+	ctx.Step(`^I stream the output produced in the last (\d+)ms$`, iStreamTheOutputProducedInTheLast)
+	ctx.Step(`^I see the streamed chunks have timestamps$`, iSeeTheStreamedChunksHaveTimestamps)
+	ctx.Step(`^I see the streamed sources are (.*)$`, iSeeTheStreamedSourcesAre)
+
+	// many
+	ctx.Step(`^the job (\S+) printing (.*) was created with label (\S+)$`, theJobPrintingWasCreatedWithLabel)
+	ctx.Step(`^I try to stream the jobs with label (\S+)$`, iTryToStreamTheJobsWithLabel)
+	ctx.Step(`^I try to stream the jobs without selecting any$`, iTryToStreamTheJobsWithoutSelectingAny)
+	ctx.Step(`^I start following the jobs with label (\S+)$`, iStartFollowingTheJobsWithLabel)
+	ctx.Step(`^I start streaming the jobs with label (\S+) without setting the follow flag$`, iStartStreamingTheJobsWithLabelWithoutSettingTheFollowFlag)
+	ctx.Step(`^I see the streamed job lines are (.*)$`, iSeeTheStreamedJobLinesAre)
+
+	// list
+	ctx.Step(`^I try to list the jobs with label (\S+)$`, iTryToListTheJobsWithLabel)
+	ctx.Step(`^I wait for the jobs with label (\S+) to be over$`, iWaitForTheJobsWithLabelToBeOver)
+	ctx.Step(`^I try to list (\d+) jobs with label (\S+) oldest first$`, iTryToListJobsWithLabelOldestFirst)
+	ctx.Step(`^I try to list the (\S+) jobs with label (\S+)$`, iTryToListTheJobsWithStatusAndLabel)
+	ctx.Step(`^I try to list the jobs of all the users$`, iTryToListTheJobsOfAllTheUsers)
+	ctx.Step(`^I try to list the jobs with page token (\S+)$`, iTryToListTheJobsWithPageToken)
+	ctx.Step(`^I try to list the next page$`, iTryToListTheNextPage)
+	ctx.Step(`^I see the listed jobs print (.*)$`, iSeeTheListedJobsPrint)
+	ctx.Step(`^I see there are more jobs to list$`, iSeeThereAreMoreJobsToList)
+	ctx.Step(`^I see there are no more jobs to list$`, iSeeThereAreNoMoreJobsToList)
+
+	// logs
+	ctx.Step(`^I try to download the job logs$`, iTryToDownloadTheJobLogs)
+	ctx.Step(`^I try to download the logs of some random job$`, iTryToDownloadTheLogsOfSomeRandomJob)
+	ctx.Step(`^I see the archive file (\S+) is (.*)$`, iSeeTheArchiveFileIs)
+	ctx.Step(`^I see the archive file (\S+) contains (.*)$`, iSeeTheArchiveFileContains)
+	ctx.Step(`^I see the archived exit code is (\d+)$`, iSeeTheArchivedExitCodeIs)
+	ctx.Step(`^I see the archived times are in order$`, iSeeTheArchivedTimesAreInOrder)
+	ctx.Step(`^I see the archived exit time is absent$`, iSeeTheArchivedExitTimeIsAbsent)
+
+	// schedule
+	ctx.Step(`^I create the schedule "(.*)" in timezone (.*)$`, iCreateTheScheduleInTimezone)
+	ctx.Step(`^I try to list my schedules$`, iTryToListMySchedules)
+	ctx.Step(`^I see the schedule in the list$`, iSeeTheScheduleInTheList)
+	ctx.Step(`^I do not see the schedule in the list$`, iDoNotSeeTheScheduleInTheList)
+	ctx.Step(`^I try to delete the schedule$`, iTryToDeleteTheSchedule)
+	ctx.Step(`^I try to delete some random schedule$`, iTryToDeleteSomeRandomSchedule)
+
+	// attach
+	ctx.Step(`^the job with terminal was created$`, theJobWithTerminalWasCreated)
+	ctx.Step(`^I try to attach to the job$`, iTryToAttachToTheJob)
+	ctx.Step(`^I attach to the job$`, iAttachToTheJob)
+	ctx.Step(`^I type (.+) into the terminal$`, iTypeIntoTheTerminal)
+	ctx.Step(`^I resize the terminal to (\d+) rows and (\d+) columns$`, iResizeTheTerminal)
+	ctx.Step(`^I see (.+) in the terminal$`, iSeeInTheTerminal)
 }
 
 func theResponseIsSuccess() error {
@@ -178,6 +308,37 @@ func iWaitForASecond() error {
 	return nil
 }
 
+// jobIsOver reports whether the job is not going to run anymore
+func jobIsOver(status api.JobStatus) bool {
+	return status == api.JobStatus_FINISHED || status == api.JobStatus_STOPPED
+}
+
+func iWaitForTheJobToBeOver() error {
+	resp, ok := scenarioState.subject.(*api.StartResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive StartResponse, but failed")
+	}
+
+	// The job might be restarted a few times, which takes a while
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, err := f.client.Status(scenarioState.ctx, &api.StatusRequest{
+			JobId: resp.GetJobId(),
+		})
+		if err != nil {
+			return fmt.Errorf("expected to get status of the job, but failed: %w", err)
+		}
+
+		if jobIsOver(status.GetStatus()) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected the job to be over, but it is still %s", status.GetStatus())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 /********************/
 // start steps
 /********************/
@@ -191,11 +352,88 @@ func iPassCommandArgument(arg string) error {
 	return nil
 }
 
+func iPassCommandInput(input string) error {
+	scenarioState.input = input
+	return nil
+}
+
+func iPassRestartPolicy(policy string, restarts int32) error {
+	// Keep the backoff short not to wait for restarts for too long
+	const backoffMs = 100
+
+	scenarioState.restart = &api.StartRequest{
+		RestartPolicy:    api.RestartPolicy(api.RestartPolicy_value[policy]),
+		MaxRestarts:      restarts,
+		RestartBackoffMs: backoffMs,
+	}
+	return nil
+}
+
+func iPassPriority(priority int32) error {
+	scenarioState.priority = priority
+	return nil
+}
+
+func iPassLogFormatJSON() error {
+	scenarioState.logFormat = api.LogFormat_JSON
+	return nil
+}
+
 func iTryToCreateNewJob() error {
-	scenarioState.subject, scenarioState.lastError = f.client.Start(scenarioState.ctx, &api.StartRequest{
-		Command: scenarioState.command,
-		Args:    scenarioState.arguments,
+	req := &api.StartRequest{
+		Command:   scenarioState.command,
+		Args:      scenarioState.arguments,
+		Stdin:     []byte(scenarioState.input),
+		Tty:       scenarioState.tty,
+		Priority:  scenarioState.priority,
+		LogFormat: scenarioState.logFormat,
+	}
+	if r := scenarioState.restart; r != nil {
+		req.RestartPolicy = r.RestartPolicy
+		req.MaxRestarts = r.MaxRestarts
+		req.RestartBackoffMs = r.RestartBackoffMs
+	}
+
+	scenarioState.subject, scenarioState.lastError = f.client.Start(scenarioState.ctx, req)
+	return nil
+}
+
+func iTryToCreateNewJobStreamingTheInput() error {
+	stream, err := f.client.StartWithInput(scenarioState.ctx)
+	if err != nil {
+		return err
+	}
+
+	err = stream.Send(&api.StartWithInputRequest{
+		Payload: &api.StartWithInputRequest_Start{Start: &api.StartRequest{
+			Command: scenarioState.command,
+			Args:    scenarioState.arguments,
+		}},
 	})
+	if err != nil {
+		return err
+	}
+
+	// The ID comes back right away, nothing is sent until it is received
+	header, err := stream.Header()
+	if err != nil {
+		return err
+	}
+	if ids := header.Get(JobIDHeader); len(ids) > 0 {
+		scenarioState.headerJobID = ids[0]
+	}
+
+	// Send the input by single bytes to make sure the chunks are glued together
+	for _, b := range []byte(scenarioState.input) {
+		err = stream.Send(&api.StartWithInputRequest{
+			Payload: &api.StartWithInputRequest_Stdin{Stdin: []byte{b}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	scenarioState.subject, scenarioState.lastError = stream.CloseAndRecv()
 	return nil
 }
 
@@ -211,6 +449,52 @@ func iGetTheJobUuid() error {
 	)
 }
 
+func iGotTheJobUuidBeforeTheInputWasSent() error {
+	resp, ok := scenarioState.subject.(*api.StartResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive StartResponse, but failed")
+	}
+	return assertExpectedAndActual(
+		assert.Equal, resp.GetJobId(), scenarioState.headerJobID,
+		fmt.Sprintf("expected to receive job uuid %s before the input, but received: %s", resp.GetJobId(), scenarioState.headerJobID),
+	)
+}
+
+func iSeeTheJobOutputIsExactlyTheInput() error {
+	resp, ok := scenarioState.subject.(*api.StartResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive StartResponse, but failed")
+	}
+
+	// The job only finishes if the end of the input reached it
+	ctx, cancel := context.WithTimeout(scenarioState.ctx, 5*time.Second)
+	defer cancel()
+	stream, err := f.client.Stream(ctx, &api.StreamRequest{
+		JobId:  resp.GetJobId(),
+		Follow: proto.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	var output []byte
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("expected the job to finish once the input is over, but failed: %w", err)
+		}
+		output = append(output, chunk.GetOutStream()...)
+	}
+
+	return assertExpectedAndActual(
+		assert.Equal, scenarioState.input, string(output),
+		fmt.Sprintf("expected the job output to be %q, but received: %q", scenarioState.input, output),
+	)
+}
+
 /********************/
 // stop steps
 /********************/
@@ -266,3 +550,1033 @@ func iSeeTheJobIsStillRunning() error {
 		fmt.Sprintf("expected the job to be running, but received: %s", resp.Status.String()),
 	)
 }
+
+func iSeeTheJobNiceValueIs(nice int32) error {
+	resp, ok := scenarioState.subject.(*api.StatusResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive StatusResponse, but failed")
+	}
+
+	return assertExpectedAndActual(
+		assert.Equal, nice, resp.Nice,
+		fmt.Sprintf("expected the job nice value to be %d, but received: %d", nice, resp.Nice),
+	)
+}
+
+func iSeeTheJobHasLogParseErrors(count int64) error {
+	resp, ok := scenarioState.subject.(*api.StatusResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive StatusResponse, but failed")
+	}
+
+	return assertExpectedAndActual(
+		assert.Equal, count, resp.LogParseErrors,
+		fmt.Sprintf("expected the job to have %d log parse errors, but received: %d", count, resp.LogParseErrors),
+	)
+}
+
+func iSeeTheJobAttemptIs(attempt int32) error {
+	resp, ok := scenarioState.subject.(*api.StatusResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive StatusResponse, but failed")
+	}
+
+	err := assertExpectedAndActual(
+		assert.Equal, attempt, resp.Attempt,
+		fmt.Sprintf("expected the job attempt to be %d, but received: %d", attempt, resp.Attempt),
+	)
+	if err != nil {
+		return err
+	}
+
+	return assertExpectedAndActual(
+		assert.Equal, int(attempt), len(resp.Attempts),
+		fmt.Sprintf("expected the job to have %d attempts in history, but received: %d", attempt, len(resp.Attempts)),
+	)
+}
+
+/********************/
+// exec steps
+/********************/
+type execResult struct {
+	output   string
+	exitCode int32
+}
+
+func iTryToExecInTheJob(command string) error {
+	resp := scenarioState.subject.(*api.StartResponse)
+	return execInJob(resp.GetJobId(), command)
+}
+
+func iTryToExecInSomeRandomJob(command string) error {
+	return execInJob("42", command)
+}
+
+func execInJob(uuid string, command string) error {
+	parts := strings.Fields(command)
+	stream, err := f.client.Exec(scenarioState.ctx, &api.ExecRequest{
+		JobId:   uuid,
+		Command: parts[0],
+		Args:    parts[1:],
+	})
+	if err != nil {
+		return err
+	}
+
+	result := &execResult{}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			scenarioState.lastError = err
+			return nil
+		}
+
+		result.output += string(resp.GetOutStream())
+		result.exitCode = resp.GetExitCode()
+	}
+
+	scenarioState.subject, scenarioState.lastError = result, nil
+	return nil
+}
+
+func iSeeTheExecOutputIs(output string) error {
+	result, ok := scenarioState.subject.(*execResult)
+	if !ok {
+		return fmt.Errorf("expected to receive exec result, but failed")
+	}
+
+	return assertExpectedAndActual(
+		assert.Equal, output, strings.TrimSpace(result.output),
+		fmt.Sprintf("expected the exec output to be %s, but received: %s", output, result.output),
+	)
+}
+
+func iSeeTheExecExitCodeIs(code int32) error {
+	result, ok := scenarioState.subject.(*execResult)
+	if !ok {
+		return fmt.Errorf("expected to receive exec result, but failed")
+	}
+
+	return assertExpectedAndActual(
+		assert.Equal, code, result.exitCode,
+		fmt.Sprintf("expected the exec exit code to be %d, but received: %d", code, result.exitCode),
+	)
+}
+
+/********************/
+// workflow steps
+/********************/
+func iSubmitTheWorkflow(table *godog.Table) error {
+	req := &api.SubmitWorkflowRequest{}
+
+	// The first row is the header: name, command, depends_on, policy.
+	// The command is followed by its arguments, if any
+	for _, row := range table.Rows[1:] {
+		command := strings.Fields(row.Cells[1].Value)
+		step := &api.WorkflowStep{
+			Name: row.Cells[0].Value,
+			Job: &api.StartRequest{
+				Command:  command[0],
+				Args:     command[1:],
+				Stdin:    []byte(scenarioState.input),
+				Priority: scenarioState.priority,
+			},
+			DependsOn: strings.Fields(row.Cells[2].Value),
+			Policy:    api.DependencyPolicy(api.DependencyPolicy_value[row.Cells[3].Value]),
+		}
+		req.Steps = append(req.Steps, step)
+	}
+
+	scenarioState.subject, scenarioState.lastError = f.client.SubmitWorkflow(scenarioState.ctx, req)
+	return nil
+}
+
+func iTryToGetStatusOfTheWorkflow() error {
+	resp, ok := scenarioState.subject.(*api.SubmitWorkflowResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive SubmitWorkflowResponse, but failed")
+	}
+
+	scenarioState.subject, scenarioState.lastError = f.client.WorkflowStatus(scenarioState.ctx, &api.WorkflowStatusRequest{
+		WorkflowId: resp.GetWorkflowId(),
+	})
+	return nil
+}
+
+func iWaitForTheWorkflowToBeOver() error {
+	resp, ok := scenarioState.subject.(*api.SubmitWorkflowResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive SubmitWorkflowResponse, but failed")
+	}
+
+	// The steps are run one after another, which might take a while
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, err := f.client.WorkflowStatus(scenarioState.ctx, &api.WorkflowStatusRequest{
+			WorkflowId: resp.GetWorkflowId(),
+		})
+		if err != nil {
+			return fmt.Errorf("expected to get status of the workflow, but failed: %w", err)
+		}
+
+		state := status.GetState()
+		if state != api.WorkflowState_PENDING && state != api.WorkflowState_RUNNING {
+			scenarioState.subject, scenarioState.lastError = status, nil
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected the workflow to be over, but it is still %s", state)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func iSeeTheWorkflowIs(state string) error {
+	resp, ok := scenarioState.subject.(*api.WorkflowStatusResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive WorkflowStatusResponse, but failed")
+	}
+
+	return assertExpectedAndActual(
+		assert.Equal, state, resp.GetState().String(),
+		fmt.Sprintf("expected the workflow to be %s, but received: %s", state, resp.GetState()),
+	)
+}
+
+func iSeeTheStepIs(name, state string) error {
+	resp, ok := scenarioState.subject.(*api.WorkflowStatusResponse)
+	if !ok {
+		return fmt.Errorf("expected to receive WorkflowStatusResponse, but failed")
+	}
+
+	for _, step := range resp.GetSteps() {
+		if step.GetName() != name {
+			continue
+		}
+		return assertExpectedAndActual(
+			assert.Equal, state, step.GetState().String(),
+			fmt.Sprintf("expected the step %s to be %s, but received: %s", name, state, step.GetState()),
+		)
+	}
+	return fmt.Errorf("expected the workflow to have step %s, but it does not", name)
+}
+
+/********************/
+// stream steps
+/********************/
+type streamResult struct {
+	output string
+	chunks []*api.StreamResponse
+}
+
+func streamRequest() *api.StreamRequest {
+	if scenarioState.stream == nil {
+		// The scenarios follow the output only when they ask for it
+		scenarioState.stream = &api.StreamRequest{Follow: proto.Bool(false)}
+	}
+	return scenarioState.stream
+}
+
+func iStreamFromOffset(offset int64) error {
+	streamRequest().FromOffset = offset
+	return nil
+}
+
+func iStreamTheLastLines(lines int32) error {
+	streamRequest().TailLines = lines
+	return nil
+}
+
+func iStreamFollowingTheOutput() error {
+	streamRequest().Follow = proto.Bool(true)
+	return nil
+}
+
+func iStreamWithoutSettingTheFollowFlag() error {
+	streamRequest().Follow = nil
+	return nil
+}
+
+func iStreamBothOutputs() error {
+	streamRequest().Combined = true
+	return nil
+}
+
+func iStreamTheLinesWhere(predicate string) error {
+	req := streamRequest()
+	req.Where = append(req.Where, predicate)
+	return nil
+}
+
+func iStreamOnlyTheFields(fields string) error {
+	streamRequest().Fields = strings.Split(fields, ",")
+	return nil
+}
+
+func iStreamTheLinesMatching(expr string) error {
+	streamRequest().Include = expr
+	return nil
+}
+
+func iStreamTheLinesNotMatching(expr string) error {
+	streamRequest().Exclude = expr
+	return nil
+}
+
+func iStreamLinesAroundThem(lines int32) error {
+	streamRequest().ContextLines = lines
+	return nil
+}
+
+func iStreamTheOutputProducedInTheLast(ms int) error {
+	since := time.Now().Add(-time.Duration(ms) * time.Millisecond)
+	streamRequest().Since = timestamppb.New(since)
+	return nil
+}
+
+func iStreamWithCompression() error {
+	scenarioState.compressed = true
+	return nil
+}
+
+func iTryToStreamTheJobOutput() error {
+	resp := scenarioState.subject.(*api.StartResponse)
+	req := streamRequest()
+	req.JobId = resp.GetJobId()
+
+	var opts []grpc.CallOption
+	if scenarioState.compressed {
+		opts = append(opts, grpc.UseCompressor(grpcgzip.Name))
+	}
+	stream, err := f.client.Stream(scenarioState.ctx, req, opts...)
+	if err != nil {
+		return err
+	}
+
+	result := &streamResult{}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			scenarioState.lastError = err
+			return nil
+		}
+
+		result.output += string(resp.GetOutStream())
+		result.chunks = append(result.chunks, resp)
+	}
+
+	scenarioState.subject, scenarioState.lastError = result, nil
+	return nil
+}
+
+func iSeeTheStreamedOutputIs(output string) error {
+	result, ok := scenarioState.subject.(*streamResult)
+	if !ok {
+		return fmt.Errorf("expected to receive stream result, but failed")
+	}
+
+	// The lines of the output are compared as if they were separated by spaces
+	actual := strings.Join(strings.Fields(result.output), " ")
+	return assertExpectedAndActual(
+		assert.Equal, output, actual,
+		fmt.Sprintf("expected the streamed output to be %s, but received: %s", output, result.output),
+	)
+}
+
+func iSeeTheStreamedOutputStartsAtOffset(offset int64) error {
+	result, ok := scenarioState.subject.(*streamResult)
+	if !ok {
+		return fmt.Errorf("expected to receive stream result, but failed")
+	}
+	if len(result.chunks) == 0 {
+		return fmt.Errorf("expected to receive some output, but received nothing")
+	}
+
+	actual := result.chunks[0].GetOffset()
+	return assertExpectedAndActual(
+		assert.Equal, offset, actual,
+		fmt.Sprintf("expected the streamed output to start at offset %d, but it starts at: %d", offset, actual),
+	)
+}
+
+func iSeeTheStreamedSourcesAre(sources string) error {
+	result, ok := scenarioState.subject.(*streamResult)
+	if !ok {
+		return fmt.Errorf("expected to receive stream result, but failed")
+	}
+
+	var actual []string
+	var last uint64
+	for _, chunk := range result.chunks {
+		if chunk.GetSeq() <= last {
+			return fmt.Errorf("expected the chunks to be ordered, but %d follows %d", chunk.GetSeq(), last)
+		}
+		last = chunk.GetSeq()
+		actual = append(actual, strings.ToLower(chunk.GetSource().String()))
+	}
+
+	return assertExpectedAndActual(
+		assert.Equal, sources, strings.Join(actual, " "),
+		fmt.Sprintf("expected the streamed sources to be %s, but received: %v", sources, actual),
+	)
+}
+
+func iSeeTheStreamedChunksHaveTimestamps() error {
+	result, ok := scenarioState.subject.(*streamResult)
+	if !ok {
+		return fmt.Errorf("expected to receive stream result, but failed")
+	}
+
+	for _, chunk := range result.chunks {
+		t := chunk.GetTimestamp()
+		if t == nil || time.Since(t.AsTime()) > time.Minute {
+			return fmt.Errorf("expected the chunk to have the recent timestamp, but received: %v", t)
+		}
+	}
+	return nil
+}
+
+/********************/
+// many steps
+/********************/
+
+// manyResult collects the lines of the jobs as name: line
+type manyResult struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *manyResult) receive(stream api.TeleWorker_StreamManyClient) error {
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		line := strings.TrimSuffix(string(resp.GetOutStream()), "\n")
+		r.lines = append(r.lines, resp.GetJobName()+": "+line)
+		r.mu.Unlock()
+	}
+}
+
+func parseLabel(label string) (string, string, error) {
+	i := strings.IndexByte(label, '=')
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid label %s", label)
+	}
+	return label[:i], label[i+1:], nil
+}
+
+func theJobPrintingWasCreatedWithLabel(name, output, label string) error {
+	key, value, err := parseLabel(label)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.client.Start(scenarioState.ctx, &api.StartRequest{
+		Command: "echo",
+		Args:    []string{output},
+		Labels:  map[string]string{"name": name, key: value},
+	})
+	return err
+}
+
+func streamManyWithLabel(label string, follow *bool) (api.TeleWorker_StreamManyClient, error) {
+	key, value, err := parseLabel(label)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.client.StreamMany(scenarioState.ctx, &api.StreamManyRequest{
+		Selector: map[string]string{key: value},
+		Follow:   follow,
+	})
+}
+
+func iTryToStreamTheJobsWithLabel(label string) error {
+	stream, err := streamManyWithLabel(label, proto.Bool(false))
+	if err != nil {
+		return err
+	}
+
+	result := &manyResult{}
+	scenarioState.lastError = result.receive(stream)
+	scenarioState.subject = result
+	return nil
+}
+
+func iTryToStreamTheJobsWithoutSelectingAny() error {
+	stream, err := f.client.StreamMany(scenarioState.ctx, &api.StreamManyRequest{})
+	if err != nil {
+		return err
+	}
+	_, scenarioState.lastError = stream.Recv()
+	return nil
+}
+
+func iStartFollowingTheJobsWithLabel(label string) error {
+	return startFollowingTheJobsWithLabel(label, proto.Bool(true))
+}
+
+func iStartStreamingTheJobsWithLabelWithoutSettingTheFollowFlag(label string) error {
+	return startFollowingTheJobsWithLabel(label, nil)
+}
+
+func startFollowingTheJobsWithLabel(label string, follow *bool) error {
+	stream, err := streamManyWithLabel(label, follow)
+	if err != nil {
+		return err
+	}
+
+	// The stream is over once the connection is closed after the scenario
+	result := &manyResult{}
+	go result.receive(stream)
+	scenarioState.subject, scenarioState.lastError = result, nil
+	return nil
+}
+
+func iSeeTheStreamedJobLinesAre(lines string) error {
+	result, ok := scenarioState.subject.(*manyResult)
+	if !ok {
+		return fmt.Errorf("expected to receive the lines of the jobs, but failed")
+	}
+
+	result.mu.Lock()
+	actual := append([]string(nil), result.lines...)
+	result.mu.Unlock()
+
+	// The jobs run concurrently, so only the lines of every job are ordered
+	expected := strings.Split(lines, ", ")
+	sort.Strings(expected)
+	sort.Strings(actual)
+	return assertExpectedAndActual(
+		assert.Equal, expected, actual,
+		fmt.Sprintf("expected the lines of the jobs to be %v, but received: %v", expected, actual),
+	)
+}
+
+/********************/
+// list steps
+/********************/
+func listJobs(req *api.ListJobsRequest) error {
+	scenarioState.listRequest = req
+	scenarioState.subject, scenarioState.lastError = f.client.ListJobs(scenarioState.ctx, req)
+	return nil
+}
+
+func labelSelector(label string) (map[string]string, error) {
+	key, value, err := parseLabel(label)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{key: value}, nil
+}
+
+func iTryToListTheJobsWithLabel(label string) error {
+	labels, err := labelSelector(label)
+	if err != nil {
+		return err
+	}
+	return listJobs(&api.ListJobsRequest{Labels: labels})
+}
+
+func iWaitForTheJobsWithLabelToBeOver(label string) error {
+	labels, err := labelSelector(label)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, err := f.client.ListJobs(scenarioState.ctx, &api.ListJobsRequest{Labels: labels})
+		if err != nil {
+			return fmt.Errorf("expected to list the jobs, but failed: %w", err)
+		}
+
+		over := len(resp.GetJobs()) > 0
+		for _, job := range resp.GetJobs() {
+			over = over && jobIsOver(job.GetStatus())
+		}
+		if over {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected the jobs with label %s to be over, but they are not", label)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func iTryToListJobsWithLabelOldestFirst(size int32, label string) error {
+	labels, err := labelSelector(label)
+	if err != nil {
+		return err
+	}
+	return listJobs(&api.ListJobsRequest{
+		Labels:   labels,
+		PageSize: size,
+		Order:    api.SortOrder_OLDEST_FIRST,
+	})
+}
+
+func iTryToListTheJobsWithStatusAndLabel(status, label string) error {
+	labels, err := labelSelector(label)
+	if err != nil {
+		return err
+	}
+	value, ok := api.JobStatus_value[strings.ToUpper(status)]
+	if !ok {
+		return fmt.Errorf("unknown job status %s", status)
+	}
+	return listJobs(&api.ListJobsRequest{
+		Labels:   labels,
+		Statuses: []api.JobStatus{api.JobStatus(value)},
+	})
+}
+
+func iTryToListTheJobsOfAllTheUsers() error {
+	return listJobs(&api.ListJobsRequest{AllUsers: true})
+}
+
+func iTryToListTheJobsWithPageToken(token string) error {
+	return listJobs(&api.ListJobsRequest{PageToken: token})
+}
+
+func iTryToListTheNextPage() error {
+	resp, ok := scenarioState.subject.(*api.ListJobsResponse)
+	if !ok {
+		return fmt.Errorf("expected the previous page of the jobs, but failed")
+	}
+
+	req := proto.Clone(scenarioState.listRequest).(*api.ListJobsRequest)
+	req.PageToken = resp.GetNextPageToken()
+	return listJobs(req)
+}
+
+func listedJobs() (*api.ListJobsResponse, error) {
+	resp, ok := scenarioState.subject.(*api.ListJobsResponse)
+	if !ok {
+		return nil, fmt.Errorf("expected to receive the jobs, but failed")
+	}
+	return resp, nil
+}
+
+func iSeeTheListedJobsPrint(outputs string) error {
+	resp, err := listedJobs()
+	if err != nil {
+		return err
+	}
+
+	expected := []string{}
+	if outputs != "nothing" {
+		expected = strings.Split(outputs, ", ")
+	}
+	actual := []string{}
+	for _, job := range resp.GetJobs() {
+		actual = append(actual, strings.Join(job.GetArgs(), " "))
+	}
+	return assertExpectedAndActual(
+		assert.Equal, expected, actual,
+		fmt.Sprintf("expected the listed jobs to print %v, but received: %v", expected, actual),
+	)
+}
+
+func iSeeThereAreMoreJobsToList() error {
+	resp, err := listedJobs()
+	if err != nil {
+		return err
+	}
+	if resp.GetNextPageToken() == "" {
+		return fmt.Errorf("expected the token of the next page, but there is none")
+	}
+	return nil
+}
+
+func iSeeThereAreNoMoreJobsToList() error {
+	resp, err := listedJobs()
+	if err != nil {
+		return err
+	}
+	if resp.GetNextPageToken() != "" {
+		return fmt.Errorf("expected no more pages, but received the token %s", resp.GetNextPageToken())
+	}
+	return nil
+}
+
+/********************/
+// logs steps
+/********************/
+type logsArchive struct {
+	files map[string]string
+}
+
+func iTryToDownloadTheJobLogs() error {
+	resp := scenarioState.subject.(*api.StartResponse)
+	stream, err := f.client.DownloadLogs(scenarioState.ctx, &api.DownloadLogsRequest{
+		JobId: resp.GetJobId(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var data bytes.Buffer
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			scenarioState.lastError = err
+			return nil
+		}
+		data.Write(resp.GetData())
+	}
+
+	gz, err := gzip.NewReader(&data)
+	if err != nil {
+		return fmt.Errorf("expected to receive gzipped archive, but failed: %w", err)
+	}
+	archive := &logsArchive{files: make(map[string]string)}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("expected to receive tar archive, but failed: %w", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		archive.files[hdr.Name] = string(content)
+	}
+
+	scenarioState.subject, scenarioState.lastError = archive, nil
+	return nil
+}
+
+func iTryToDownloadTheLogsOfSomeRandomJob() error {
+	stream, err := f.client.DownloadLogs(scenarioState.ctx, &api.DownloadLogsRequest{
+		JobId: "42",
+	})
+	if err != nil {
+		return err
+	}
+	_, scenarioState.lastError = stream.Recv()
+	return nil
+}
+
+func archivedFile(name string) (string, error) {
+	archive, ok := scenarioState.subject.(*logsArchive)
+	if !ok {
+		return "", fmt.Errorf("expected to receive logs archive, but failed")
+	}
+
+	file, found := archive.files[name]
+	if !found {
+		return "", fmt.Errorf("expected the archive to contain %s, but it does not", name)
+	}
+	return file, nil
+}
+
+func iSeeTheArchiveFileIs(name, content string) error {
+	file, err := archivedFile(name)
+	if err != nil {
+		return err
+	}
+	actual := strings.Join(strings.Fields(file), " ")
+	return assertExpectedAndActual(
+		assert.Equal, content, actual,
+		fmt.Sprintf("expected %s to be %s, but received: %s", name, content, file),
+	)
+}
+
+func iSeeTheArchiveFileContains(name, content string) error {
+	file, err := archivedFile(name)
+	if err != nil {
+		return err
+	}
+	return assertExpectedAndActual(
+		assert.Contains, file, content,
+		fmt.Sprintf("expected %s to contain %s, but received: %s", name, content, file),
+	)
+}
+
+func iSeeTheArchivedExitCodeIs(code int) error {
+	file, err := archivedFile("metadata.json")
+	if err != nil {
+		return err
+	}
+
+	var meta struct {
+		ExitCode int `json:"exit_code"`
+	}
+	err = json.Unmarshal([]byte(file), &meta)
+	if err != nil {
+		return fmt.Errorf("expected the archive to contain valid metadata, but failed: %w", err)
+	}
+	return assertExpectedAndActual(
+		assert.Equal, code, meta.ExitCode,
+		fmt.Sprintf("expected the archived exit code to be %d, but received: %d", code, meta.ExitCode),
+	)
+}
+
+func iSeeTheArchivedTimesAreInOrder() error {
+	file, err := archivedFile("metadata.json")
+	if err != nil {
+		return err
+	}
+
+	var meta struct {
+		CreatedAt  time.Time `json:"created_at"`
+		ExitedAt   time.Time `json:"exited_at"`
+		ArchivedAt time.Time `json:"archived_at"`
+		Attempts   []struct {
+			StartedAt time.Time `json:"started_at"`
+			ExitedAt  time.Time `json:"exited_at"`
+		} `json:"attempts"`
+	}
+	err = json.Unmarshal([]byte(file), &meta)
+	if err != nil {
+		return fmt.Errorf("expected the archive to contain valid metadata, but failed: %w", err)
+	}
+	if len(meta.Attempts) != 1 {
+		return fmt.Errorf("expected the archive to contain a single attempt, but received: %d", len(meta.Attempts))
+	}
+
+	// The job is created, started, exited and archived in this order
+	times := []time.Time{meta.CreatedAt, meta.Attempts[0].StartedAt, meta.Attempts[0].ExitedAt, meta.ExitedAt, meta.ArchivedAt}
+	for i := 1; i < len(times); i++ {
+		if times[i-1].IsZero() || times[i].Before(times[i-1]) {
+			return fmt.Errorf("expected the archived times to be in order, but received: %s", file)
+		}
+	}
+	return nil
+}
+
+func iSeeTheArchivedExitTimeIsAbsent() error {
+	file, err := archivedFile("metadata.json")
+	if err != nil {
+		return err
+	}
+
+	var meta struct {
+		ExitedAt *time.Time `json:"exited_at"`
+		Attempts []struct {
+			ExitedAt *time.Time `json:"exited_at"`
+		} `json:"attempts"`
+	}
+	err = json.Unmarshal([]byte(file), &meta)
+	if err != nil {
+		return fmt.Errorf("expected the archive to contain valid metadata, but failed: %w", err)
+	}
+
+	// Neither the job nor its only attempt has exited yet
+	absent := meta.ExitedAt == nil
+	for _, attempt := range meta.Attempts {
+		absent = absent && attempt.ExitedAt == nil
+	}
+	if !absent {
+		return fmt.Errorf("expected the archive to have no exit time, but received: %s", file)
+	}
+	return nil
+}
+
+/********************/
+// schedule steps
+/********************/
+func iCreateTheScheduleInTimezone(cron, timezone string) error {
+	resp, err := f.client.CreateSchedule(scenarioState.ctx, &api.CreateScheduleRequest{
+		Cron:     cron,
+		Timezone: timezone,
+		Job: &api.StartRequest{
+			Command:  scenarioState.command,
+			Args:     scenarioState.arguments,
+			Priority: scenarioState.priority,
+		},
+	})
+	scenarioState.subject, scenarioState.lastError = resp, err
+	scenarioState.scheduleID = resp.GetScheduleId()
+	return nil
+}
+
+func iTryToListMySchedules() error {
+	scenarioState.subject, scenarioState.lastError = f.client.ListSchedules(scenarioState.ctx, &api.ListSchedulesRequest{})
+	return nil
+}
+
+func scheduleListed() (bool, error) {
+	resp, ok := scenarioState.subject.(*api.ListSchedulesResponse)
+	if !ok {
+		return false, fmt.Errorf("expected to receive ListSchedulesResponse, but failed")
+	}
+
+	for _, sc := range resp.GetSchedules() {
+		if sc.GetScheduleId() == scenarioState.scheduleID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func iSeeTheScheduleInTheList() error {
+	listed, err := scheduleListed()
+	if err != nil {
+		return err
+	}
+	if !listed {
+		return fmt.Errorf("expected the schedule %s to be listed, but it is not", scenarioState.scheduleID)
+	}
+	return nil
+}
+
+func iDoNotSeeTheScheduleInTheList() error {
+	listed, err := scheduleListed()
+	if err != nil {
+		return err
+	}
+	if listed {
+		return fmt.Errorf("expected the schedule %s not to be listed, but it is", scenarioState.scheduleID)
+	}
+	return nil
+}
+
+func iTryToDeleteTheSchedule() error {
+	_, scenarioState.lastError = f.client.DeleteSchedule(scenarioState.ctx, &api.DeleteScheduleRequest{
+		ScheduleId: scenarioState.scheduleID,
+	})
+	return nil
+}
+
+func iTryToDeleteSomeRandomSchedule() error {
+	_, scenarioState.lastError = f.client.DeleteSchedule(scenarioState.ctx, &api.DeleteScheduleRequest{
+		ScheduleId: "42",
+	})
+	return nil
+}
+
+/********************/
+// attach steps
+/********************/
+func theJobWithTerminalWasCreated() error {
+	scenarioState.tty = true
+	return theJobWasCreated()
+}
+
+func iTryToAttachToTheJob() error {
+	resp := scenarioState.subject.(*api.StartResponse)
+	uuid := resp.GetJobId()
+
+	// The session lasts as long as the job does, so
+	// there is no need to wait for the job to finish
+	ctx, cancel := context.WithTimeout(scenarioState.ctx, time.Second)
+	defer cancel()
+
+	stream, err := f.client.Attach(ctx)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&api.AttachRequest{
+		Payload: &api.AttachRequest_JobId{JobId: uuid},
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if err == io.EOF || status.Code(err) == codes.DeadlineExceeded {
+		err = nil
+	}
+	scenarioState.lastError = err
+	return nil
+}
+
+// attachSession collects the terminal output of the job
+// while the scenario keeps typing into the terminal
+type attachSession struct {
+	stream api.TeleWorker_AttachClient
+
+	mu     sync.Mutex
+	output bytes.Buffer
+}
+
+func (a *attachSession) receive() {
+	for {
+		resp, err := a.stream.Recv()
+		if err != nil {
+			return
+		}
+		a.mu.Lock()
+		a.output.Write(resp.GetOutStream())
+		a.mu.Unlock()
+	}
+}
+
+func iAttachToTheJob() error {
+	resp := scenarioState.subject.(*api.StartResponse)
+
+	// The session is over once the connection is closed after the scenario
+	stream, err := f.client.Attach(scenarioState.ctx)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&api.AttachRequest{
+		Payload: &api.AttachRequest_JobId{JobId: resp.GetJobId()},
+	})
+	if err != nil {
+		return err
+	}
+
+	scenarioState.attach = &attachSession{stream: stream}
+	go scenarioState.attach.receive()
+	return nil
+}
+
+func iTypeIntoTheTerminal(text string) error {
+	return scenarioState.attach.stream.Send(&api.AttachRequest{
+		Payload: &api.AttachRequest_Stdin{Stdin: []byte(text + "\n")},
+	})
+}
+
+func iResizeTheTerminal(rows, cols int) error {
+	return scenarioState.attach.stream.Send(&api.AttachRequest{
+		Payload: &api.AttachRequest_Resize{Resize: &api.TerminalSize{Rows: uint32(rows), Cols: uint32(cols)}},
+	})
+}
+
+func iSeeInTheTerminal(text string) error {
+	a := scenarioState.attach
+
+	// The output comes through the terminal asynchronously
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		a.mu.Lock()
+		output := a.output.String()
+		a.mu.Unlock()
+
+		if strings.Contains(output, text) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected to see %q in the terminal, but received: %q", text, output)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
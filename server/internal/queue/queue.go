@@ -0,0 +1,233 @@
+package queue
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+)
+
+// Ordering defines which of the queued jobs is started first
+type Ordering int
+
+const (
+	// FIFO starts the jobs in the order they were submitted
+	FIFO Ordering = iota
+	// Priority starts the jobs with the higher priority first,
+	// the jobs with the same priority are started in FIFO order
+	Priority
+)
+
+type entry struct {
+	job *tw.Job
+	// priority follows the nice values semantics,
+	// i.e. the lower the value the higher the priority
+	priority int
+	seq      uint64
+}
+
+// Queue limits the number of the jobs running at the same time. The jobs
+// submitted while there are no free slots are queued and started
+// automatically once some of the running jobs are over
+type Queue struct {
+	mu sync.Mutex
+	// maxRunning and maxPerUser limit the number of running
+	// jobs in total and for every user, 0 means unlimited
+	maxRunning int
+	maxPerUser int
+	ordering   Ordering
+
+	pending []*entry
+	seq     uint64
+	running int
+	perUser map[string]int
+}
+
+// Option is function used for applying configurations to queue
+type Option func(*Queue)
+
+func New(options ...Option) *Queue {
+	q := &Queue{
+		perUser: make(map[string]int),
+	}
+
+	for _, opt := range options {
+		opt(q)
+	}
+
+	return q
+}
+
+// WithMaxRunning limits the number of the jobs running on the server
+func WithMaxRunning(n int) Option {
+	return func(q *Queue) {
+		q.maxRunning = n
+	}
+}
+
+// WithMaxPerUser limits the number of the jobs running for every user
+func WithMaxPerUser(n int) Option {
+	return func(q *Queue) {
+		q.maxPerUser = n
+	}
+}
+
+// WithOrdering sets the order the queued jobs are started in
+func WithOrdering(ordering Ordering) Option {
+	return func(q *Queue) {
+		q.ordering = ordering
+	}
+}
+
+// Submit starts the job right away if there is a free slot for its owner,
+// otherwise the job is queued. The error is returned only if the job
+// failed to start or to be queued, errors of the jobs started later
+// are reported through their state
+func (q *Queue) Submit(job *tw.Job, priority int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Whenever a slot is freed, every queued job which fits in is started,
+	// so if the job fits in now, nobody in the queue is skipped
+	if q.fits(job.User) {
+		return q.start(job)
+	}
+
+	err := job.Enqueue()
+	if err != nil {
+		return err
+	}
+
+	q.seq++
+	q.pending = append(q.pending, &entry{
+		job:      job,
+		priority: priority,
+		seq:      q.seq,
+	})
+	q.sort()
+
+	return nil
+}
+
+// Position returns the position of the job in the queue starting from 1,
+// which is the job to be started next. Returns 0 if the job is not queued
+func (q *Queue) Position(job *tw.Job) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.prune()
+	for i, e := range q.pending {
+		if e.job == job {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// fits checks whether the job of the user can be started right
+// now without exceeding the limits. Should be called holding the lock
+func (q *Queue) fits(user string) bool {
+	if q.maxRunning > 0 && q.running >= q.maxRunning {
+		return false
+	}
+	if q.maxPerUser > 0 && q.perUser[user] >= q.maxPerUser {
+		return false
+	}
+	return true
+}
+
+// Adopt occupies the slot for the job which is already running, e.g.
+// adopted after the restart, until the job is over. The job is counted
+// even if the limits are exceeded, so the next jobs wait for it instead
+func (q *Queue) Adopt(job *tw.Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.occupy(job)
+}
+
+// start launches the job occupying the slot until the job is over.
+// Should be called holding the lock
+func (q *Queue) start(job *tw.Job) error {
+	err := job.Start()
+	if err != nil {
+		return err
+	}
+
+	q.occupy(job)
+	return nil
+}
+
+// occupy takes the slot of the running job and frees it once
+// the job is over. Should be called holding the lock
+func (q *Queue) occupy(job *tw.Job) {
+	q.running++
+	q.perUser[job.User]++
+
+	go func() {
+		<-job.Done()
+		q.release(job.User)
+	}()
+}
+
+// release frees the slot of the finished job and
+// starts the queued jobs which fit in now
+func (q *Queue) release(user string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	q.perUser[user]--
+	if q.perUser[user] == 0 {
+		delete(q.perUser, user)
+	}
+
+	q.dispatch()
+}
+
+// dispatch starts the queued jobs in order while there are
+// free slots for them. Should be called holding the lock
+func (q *Queue) dispatch() {
+	q.prune()
+
+	pending := q.pending[:0]
+	for _, e := range q.pending {
+		if !q.fits(e.job.User) {
+			// Other users might still have free slots
+			pending = append(pending, e)
+			continue
+		}
+
+		err := q.start(e.job)
+		if err != nil {
+			log.Printf("queued job %s failed to start: %v", e.job.ID, err)
+		}
+	}
+	q.pending = pending
+}
+
+// prune drops the jobs which were cancelled while being
+// queued. Should be called holding the lock
+func (q *Queue) prune() {
+	pending := q.pending[:0]
+	for _, e := range q.pending {
+		if e.job.Status().Status == api.JobStatus_QUEUED {
+			pending = append(pending, e)
+		}
+	}
+	q.pending = pending
+}
+
+// sort orders the queue according to the ordering
+// of the queue. Should be called holding the lock
+func (q *Queue) sort() {
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		a, b := q.pending[i], q.pending[j]
+		if q.ordering == Priority && a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		return a.seq < b.seq
+	})
+}
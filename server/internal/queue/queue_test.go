@@ -0,0 +1,122 @@
+package queue
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Jobs are launched by calling the test binary itself,
+	// so it has to behave the same way the server does
+	tw.InternalCallHandle()
+
+	os.Exit(m.Run())
+}
+
+func newJob(t *testing.T, user string, seconds string) *tw.Job {
+	job, err := tw.NewJob("sleep", []string{seconds}, tw.WithUsername(user))
+	require.NoError(t, err)
+	return job
+}
+
+func status(job *tw.Job) api.JobStatus {
+	return job.Status().Status
+}
+
+func TestQueueLimitsPerUser(t *testing.T) {
+	q := New(WithMaxPerUser(1))
+
+	first := newJob(t, "alice", "0.5")
+	require.NoError(t, q.Submit(first, 0))
+	assert.Equal(t, api.JobStatus_ALIVE, status(first))
+
+	second := newJob(t, "alice", "0")
+	require.NoError(t, q.Submit(second, 0))
+	assert.Equal(t, api.JobStatus_QUEUED, status(second))
+	assert.Equal(t, 1, q.Position(second))
+
+	// Other users have slots of their own
+	other := newJob(t, "bob", "0")
+	require.NoError(t, q.Submit(other, 0))
+	assert.Equal(t, api.JobStatus_ALIVE, status(other))
+
+	<-first.Done()
+	<-second.Done()
+	assert.Equal(t, api.JobStatus_FINISHED, status(second))
+	assert.Equal(t, 0, q.Position(second))
+}
+
+func TestQueueCancelsStoppedJob(t *testing.T) {
+	q := New(WithMaxRunning(1))
+
+	first := newJob(t, "alice", "0.5")
+	require.NoError(t, q.Submit(first, 0))
+
+	cancelled := newJob(t, "alice", "0")
+	require.NoError(t, q.Submit(cancelled, 0))
+	next := newJob(t, "bob", "0")
+	require.NoError(t, q.Submit(next, 0))
+	assert.Equal(t, 2, q.Position(next))
+
+	require.NoError(t, cancelled.Stop())
+	assert.Equal(t, api.JobStatus_STOPPED, status(cancelled))
+	assert.Equal(t, 0, q.Position(cancelled))
+	assert.Equal(t, 1, q.Position(next))
+
+	select {
+	case <-next.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued job was not started after the slot was freed")
+	}
+	assert.Equal(t, api.JobStatus_FINISHED, status(next))
+	assert.Empty(t, cancelled.Status().Attempts)
+}
+
+func TestQueuePriorityOrdering(t *testing.T) {
+	q := New(WithMaxRunning(1), WithOrdering(Priority))
+
+	first := newJob(t, "alice", "0.5")
+	require.NoError(t, q.Submit(first, 0))
+
+	low := newJob(t, "alice", "0")
+	require.NoError(t, q.Submit(low, 10))
+	normal := newJob(t, "alice", "0")
+	require.NoError(t, q.Submit(normal, 0))
+	high := newJob(t, "alice", "0")
+	require.NoError(t, q.Submit(high, -5))
+
+	assert.Equal(t, 1, q.Position(high))
+	assert.Equal(t, 2, q.Position(normal))
+	assert.Equal(t, 3, q.Position(low))
+
+	<-low.Done()
+	highStarted := high.Status().Attempts[0].StartedAt
+	lowStarted := low.Status().Attempts[0].StartedAt
+	assert.True(t, highStarted.Before(lowStarted))
+}
+
+func TestQueueCountsAdoptedJob(t *testing.T) {
+	q := New(WithMaxRunning(1))
+
+	// The job was started before the queue, e.g. adopted after the restart
+	adopted := newJob(t, "alice", "0.5")
+	require.NoError(t, adopted.Start())
+	q.Adopt(adopted)
+
+	next := newJob(t, "bob", "0")
+	require.NoError(t, q.Submit(next, 0))
+	assert.Equal(t, api.JobStatus_QUEUED, status(next))
+
+	select {
+	case <-next.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued job was not started after the adopted one was over")
+	}
+	assert.Equal(t, api.JobStatus_FINISHED, status(adopted))
+}
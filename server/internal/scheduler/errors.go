@@ -0,0 +1,49 @@
+package scheduler
+
+import "fmt"
+
+type NotFoundError struct {
+	id string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("schedule %s was not found", e.id)
+}
+
+type MissingCommandError struct{}
+
+func (e *MissingCommandError) Error() string {
+	return "schedule has no command to run"
+}
+
+type InvalidSpecError struct {
+	spec string
+	err  error
+}
+
+func (e *InvalidSpecError) Error() string {
+	return fmt.Sprintf("invalid cron expression %q: %v", e.spec, e.err)
+}
+
+type DescriptorError struct{}
+
+func (e *DescriptorError) Error() string {
+	return "descriptors are not supported, use the 5 fields instead"
+}
+
+type FieldsCountError struct {
+	count int
+}
+
+func (e *FieldsCountError) Error() string {
+	return fmt.Sprintf("expected exactly 5 fields, found %d", e.count)
+}
+
+type InvalidTimezoneError struct {
+	tz  string
+	err error
+}
+
+func (e *InvalidTimezoneError) Error() string {
+	return fmt.Sprintf("invalid timezone %q: %v", e.tz, e.err)
+}
@@ -0,0 +1,235 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/robfig/cron/v3"
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+)
+
+// historyLimit is the number of the latest runs kept for every schedule
+const historyLimit = 100
+
+// Launcher starts the job described by the request
+// on behalf of the schedule owner
+type Launcher func(req *api.StartRequest) (*tw.Job, error)
+
+// Run describes one firing of the schedule
+type Run struct {
+	StartedAt time.Time
+	JobID     string
+	Err       error
+}
+
+// Schedule starts the same job regularly according to the cron expression
+type Schedule struct {
+	ID       uuid.UUID
+	User     string
+	Spec     string
+	Timezone string
+	Overlap  api.OverlapPolicy
+	Request  *api.StartRequest
+
+	launch  Launcher
+	entryID cron.EntryID
+
+	mu      sync.RWMutex
+	history []Run
+}
+
+// New validates the cron expression and the timezone and creates
+// the schedule, which is yet to be added to the scheduler
+func New(user, spec, timezone string, overlap api.OverlapPolicy, req *api.StartRequest) (*Schedule, error) {
+	if req.GetCommand() == "" {
+		return nil, &MissingCommandError{}
+	}
+
+	if _, err := parse(spec, timezone); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error generating uuid: %w", err)
+	}
+
+	return &Schedule{
+		ID:       id,
+		User:     user,
+		Spec:     spec,
+		Timezone: timezone,
+		Overlap:  overlap,
+		Request:  req,
+	}, nil
+}
+
+// parse parses the standard 5-field cron expression
+// evaluated in the timezone provided
+func parse(spec, timezone string) (cron.Schedule, error) {
+	// The parser accepts the descriptors like "@every 1s" as well, which
+	// would let the jobs be started more often than once a minute.
+	// The timezone has its own field, so the prefix is not allowed either
+	fields := strings.Fields(spec)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		return nil, &InvalidSpecError{spec, &DescriptorError{}}
+	}
+	if len(fields) != 5 {
+		return nil, &InvalidSpecError{spec, &FieldsCountError{len(fields)}}
+	}
+
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, &InvalidTimezoneError{timezone, err}
+		}
+		spec = fmt.Sprintf("CRON_TZ=%s %s", timezone, spec)
+	}
+
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, &InvalidSpecError{spec, err}
+	}
+	return sched, nil
+}
+
+// Run starts the job and, unless the launch failed, waits for it to finish,
+// so the overlap policy wrappers know whether the previous job is still running
+func (sc *Schedule) Run() {
+	run := Run{StartedAt: time.Now()}
+	job, err := sc.launch(sc.Request)
+	if err != nil {
+		run.Err = err
+	} else {
+		run.JobID = job.ID.String()
+	}
+	sc.record(run)
+
+	if job != nil {
+		<-job.Done()
+	}
+}
+
+func (sc *Schedule) record(run Run) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.history = append(sc.history, run)
+	if len(sc.history) > historyLimit {
+		sc.history = sc.history[len(sc.history)-historyLimit:]
+	}
+}
+
+// History returns the latest runs of the schedule, oldest first
+func (sc *Schedule) History() []Run {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	history := make([]Run, len(sc.history))
+	copy(history, sc.history)
+	return history
+}
+
+// Scheduler keeps all the schedules and fires them on time
+type Scheduler struct {
+	mu        sync.RWMutex
+	cron      *cron.Cron
+	schedules map[string]*Schedule
+}
+
+func NewScheduler() *Scheduler {
+	s := &Scheduler{
+		cron:      cron.New(),
+		schedules: make(map[string]*Schedule),
+	}
+	s.cron.Start()
+	return s
+}
+
+// Add starts firing the schedule, launching the jobs using the launcher provided
+func (s *Scheduler) Add(sc *Schedule, launch Launcher) error {
+	sched, err := parse(sc.Spec, sc.Timezone)
+	if err != nil {
+		return err
+	}
+	sc.launch = launch
+
+	var wrapper cron.JobWrapper
+	switch sc.Overlap {
+	case api.OverlapPolicy_SKIP:
+		wrapper = cron.SkipIfStillRunning(cron.DiscardLogger)
+	case api.OverlapPolicy_QUEUE:
+		wrapper = cron.DelayIfStillRunning(cron.DiscardLogger)
+	default:
+		// Every run is started in its own goroutine anyway
+		wrapper = func(j cron.Job) cron.Job { return j }
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc.entryID = s.cron.Schedule(sched, cron.NewChain(wrapper).Then(sc))
+	s.schedules[sc.ID.String()] = sc
+
+	log.Printf("schedule %s of user %s is added: %s", sc.ID, sc.User, sc.Spec)
+	return nil
+}
+
+func (s *Scheduler) Get(id string) (*Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, ok := s.schedules[id]
+	if !ok {
+		return nil, &NotFoundError{id}
+	}
+	return sc, nil
+}
+
+// List returns the schedules created by the user
+func (s *Scheduler) List(user string) []*Schedule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := []*Schedule{}
+	for _, sc := range s.schedules {
+		if sc.User == user {
+			list = append(list, sc)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID.String() < list[j].ID.String()
+	})
+	return list
+}
+
+// Remove stops firing the schedule. The jobs
+// already started by it keep running
+func (s *Scheduler) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, ok := s.schedules[id]
+	if !ok {
+		return &NotFoundError{id}
+	}
+
+	s.cron.Remove(sc.entryID)
+	delete(s.schedules, id)
+	return nil
+}
+
+// NextRun returns the time the schedule fires next
+func (s *Scheduler) NextRun(sc *Schedule) time.Time {
+	return s.cron.Entry(sc.entryID).Next
+}
+
+// Stop stops firing all the schedules
+func (s *Scheduler) Stop() {
+	s.cron.Stop()
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewValidates(t *testing.T) {
+	req := &api.StartRequest{Command: "backup.sh"}
+
+	tests := []struct {
+		name     string
+		spec     string
+		timezone string
+		req      *api.StartRequest
+		err      error
+	}{
+		{
+			name: "valid",
+			spec: "0 2 * * *",
+			req:  req,
+		},
+		{
+			name:     "valid with timezone",
+			spec:     "*/15 9-17 * * mon-fri",
+			timezone: "Europe/Prague",
+			req:      req,
+		},
+		{
+			name: "missing command",
+			spec: "0 2 * * *",
+			req:  &api.StartRequest{},
+			err:  &MissingCommandError{},
+		},
+		{
+			name: "seconds are not supported",
+			spec: "0 0 2 * * *",
+			req:  req,
+			err:  &InvalidSpecError{},
+		},
+		{
+			name: "descriptors are not supported",
+			spec: "@hourly",
+			req:  req,
+			err:  &InvalidSpecError{},
+		},
+		{
+			name: "intervals are not supported",
+			spec: "@every 1s",
+			req:  req,
+			err:  &InvalidSpecError{},
+		},
+		{
+			name: "timezone prefix is not supported",
+			spec: "CRON_TZ=UTC @every 1s",
+			req:  req,
+			err:  &InvalidSpecError{},
+		},
+		{
+			name: "invalid expression",
+			spec: "61 * * * *",
+			req:  req,
+			err:  &InvalidSpecError{},
+		},
+		{
+			name:     "invalid timezone",
+			spec:     "0 2 * * *",
+			timezone: "Mars/Olympus",
+			req:      req,
+			err:      &InvalidTimezoneError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New("alice", tt.spec, tt.timezone, api.OverlapPolicy_SKIP, tt.req)
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.IsType(t, tt.err, err)
+		})
+	}
+}
+
+func TestSchedulerManagesSchedules(t *testing.T) {
+	s := NewScheduler()
+	defer s.Stop()
+
+	launchErr := errors.New("no luck")
+	launch := func(req *api.StartRequest) (*tw.Job, error) {
+		return nil, launchErr
+	}
+
+	sc, err := New("alice", "0 2 * * *", "UTC", api.OverlapPolicy_QUEUE, &api.StartRequest{Command: "backup.sh"})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Add(sc, launch))
+
+	assert.Len(t, s.List("alice"), 1)
+	assert.Empty(t, s.List("bob"))
+	assert.False(t, s.NextRun(sc).IsZero())
+
+	// Failed launches are kept in the history as well
+	sc.Run()
+	history := sc.History()
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, launchErr, history[0].Err)
+		assert.Empty(t, history[0].JobID)
+	}
+
+	assert.NoError(t, s.Remove(sc.ID.String()))
+	assert.IsType(t, &NotFoundError{}, s.Remove(sc.ID.String()))
+	assert.Empty(t, s.List("alice"))
+}
@@ -17,3 +17,11 @@ type AlreadyInError struct {
 func (e *AlreadyInError) Error() string {
 	return fmt.Sprintf("job %s is already in the storage", e.id)
 }
+
+type InvalidPageTokenError struct {
+	token string
+}
+
+func (e *InvalidPageTokenError) Error() string {
+	return fmt.Sprintf("page token %q is invalid", e.token)
+}
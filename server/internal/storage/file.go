@@ -0,0 +1,424 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+)
+
+const (
+	journalName  = "journal.jsonl"
+	snapshotName = "snapshot.json"
+	// snapshotInterval is how often the journal is replaced by the snapshot
+	snapshotInterval = 1 * time.Minute
+	// maxEntrySize limits the size of a single journal line
+	maxEntrySize = 16 * 1024 * 1024
+)
+
+// The operations recorded in the journal
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// File keeps the jobs in memory the same way Memory does, while also
+// recording them in the data directory, so the jobs survive the server
+// restart. Every job put to the storage is appended to the journal, and
+// once again whenever its status changes, along with the evicted ones.
+// The snapshot of all the jobs periodically replaces the journal, so it
+// doesn't grow forever. The jobs are restored from the snapshot and the
+// journal written after it, see tw.RestoreJob
+type File struct {
+	*Memory
+	dir string
+
+	// mu guards the journal
+	mu      sync.Mutex
+	journal *os.File
+	// entries is the number of the entries written since the last snapshot
+	entries   int
+	closeOnce sync.Once
+}
+
+// NewFileStorage restores the jobs saved in the directory and starts
+// recording the new ones there. The options configure the storage the
+// same way they configure Memory, the restore options are applied to
+// every restored job
+func NewFileStorage(dir string, restore []tw.Option, options ...Option) (*File, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating storage directory: %w", err)
+	}
+
+	records, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{dir: dir}
+	options = append(options[:len(options):len(options)], WithEvictionHook(f.evicted))
+	f.Memory = NewMemStorage(options...)
+
+	for id, r := range records {
+		job, err := r.job(restore)
+		if err != nil {
+			f.Memory.Close()
+			return nil, fmt.Errorf("error restoring job %s: %w", id, err)
+		}
+		f.Memory.data[id] = job
+		// The job adopted from its shim is still running
+		f.recordChanges(job)
+	}
+
+	// The restored jobs are saved right away, so the
+	// journal left from the previous run is not needed
+	f.journal, err = os.OpenFile(filepath.Join(dir, journalName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		f.Memory.Close()
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	err = f.snapshot()
+	if err != nil {
+		f.Memory.Close()
+		f.journal.Close()
+		return nil, err
+	}
+
+	ticker := time.NewTicker(snapshotInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// The failed snapshot is retried on the next tick,
+				// the journal keeps everything meanwhile
+				f.mu.Lock()
+				if f.entries > 0 {
+					f.snapshot()
+				}
+				f.mu.Unlock()
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+
+	return f, nil
+}
+
+// Put stores the job and appends it to the journal. The job is kept in
+// memory even if the journal can't be written, so it is at least saved
+// by the next snapshot
+func (f *File) Put(job *tw.Job) error {
+	err := f.Memory.Put(job)
+	if err != nil {
+		return err
+	}
+
+	changed := job.Changed()
+	err = f.append(&entry{Op: opPut, Job: newRecord(job)})
+	f.recordChangesSince(job, changed)
+	return err
+}
+
+// recordChanges appends the job to the journal once again every
+// time its status changes, e.g. it is started or exits, until it is over
+func (f *File) recordChanges(job *tw.Job) {
+	f.recordChangesSince(job, job.Changed())
+}
+
+// recordChangesSince does the same as recordChanges, the changed
+// channel was requested right before the job was last recorded
+func (f *File) recordChangesSince(job *tw.Job, changed <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-changed:
+			case <-job.Done():
+				// The last change comes before the job is over,
+				// so there is nothing else to record unless it
+				// has happened since the job was last recorded
+				select {
+				case <-changed:
+				default:
+					return
+				}
+			case <-f.stop:
+				return
+			}
+
+			// The next change might happen while this one is recorded
+			changed = job.Changed()
+			f.append(&entry{Op: opPut, Job: newRecord(job)})
+		}
+	}()
+}
+
+// evicted records the job evicted due to the TTL
+func (f *File) evicted(job *tw.Job) {
+	f.append(&entry{Op: opDelete, ID: job.ID.String()})
+}
+
+// Close saves the snapshot of all the jobs and stops recording them
+func (f *File) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		f.Memory.Close()
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		err = f.snapshot()
+		closeErr := f.journal.Close()
+		if err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+func (f *File) append(e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error encoding journal entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, err = f.journal.Write(data)
+	if err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	f.entries++
+	return nil
+}
+
+// snapshot saves all the jobs and empties the journal. The snapshot replaces
+// the previous one atomically, so a crash in between leaves either of them
+// along with the journal, which is replayed over it. Should be called
+// holding the lock
+func (f *File) snapshot() error {
+	s := &snapshot{SavedAt: time.Now()}
+	f.Memory.mu.RLock()
+	for _, job := range f.Memory.data {
+		s.Jobs = append(s.Jobs, newRecord(job))
+	}
+	f.Memory.mu.RUnlock()
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(f.dir, snapshotName+".")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(f.dir, snapshotName))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+
+	err = f.journal.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("error truncating journal: %w", err)
+	}
+	f.entries = 0
+	return nil
+}
+
+// load reads the saved jobs from the snapshot and the journal
+func load(dir string) (map[string]*record, error) {
+	records := make(map[string]*record)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, snapshotName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading snapshot: %w", err)
+	}
+	if err == nil {
+		var s snapshot
+		err = json.Unmarshal(data, &s)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding snapshot: %w", err)
+		}
+		for _, r := range s.Jobs {
+			records[r.ID] = r
+		}
+	}
+
+	journal, err := os.Open(filepath.Join(dir, journalName))
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	defer journal.Close()
+
+	scanner := bufio.NewScanner(journal)
+	scanner.Buffer(nil, maxEntrySize)
+	for scanner.Scan() {
+		var e entry
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			// The server must have stopped while writing the entry,
+			// nothing was written after it
+			break
+		}
+
+		switch e.Op {
+		case opPut:
+			if e.Job != nil {
+				records[e.Job.ID] = e.Job
+			}
+		case opDelete:
+			delete(records, e.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+
+	return records, nil
+}
+
+// entry is the line of the journal
+type entry struct {
+	Op  string  `json:"op"`
+	Job *record `json:"job,omitempty"`
+	ID  string  `json:"id,omitempty"`
+}
+
+type snapshot struct {
+	SavedAt time.Time `json:"saved_at"`
+	Jobs    []*record `json:"jobs"`
+}
+
+// record is the saved state of the job
+type record struct {
+	ID             string            `json:"id"`
+	User           string            `json:"user"`
+	Command        string            `json:"command"`
+	Args           []string          `json:"args"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Status         string            `json:"status"`
+	ExitCode       int               `json:"exit_code"`
+	Error          string            `json:"error,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	ExitedAt       time.Time         `json:"exited_at,omitempty"`
+	MemoryMB       int               `json:"memory_limit_mb"`
+	CpuWeight      int               `json:"cpu_limit_percentage"`
+	IOWeight       int               `json:"io_limit_percentage"`
+	RestartPolicy  string            `json:"restart_policy"`
+	MaxRestarts    int               `json:"max_restarts"`
+	RestartBackoff time.Duration     `json:"restart_backoff"`
+	Nice           int               `json:"nice"`
+	LogFormat      string            `json:"log_format"`
+	Attempts       []recordAttempt   `json:"attempts"`
+}
+
+type recordAttempt struct {
+	ExitCode  int       `json:"exit_code"`
+	StartedAt time.Time `json:"started_at"`
+	ExitedAt  time.Time `json:"exited_at,omitempty"`
+}
+
+func newRecord(job *tw.Job) *record {
+	state := job.Status()
+
+	r := &record{
+		ID:             job.ID.String(),
+		User:           job.User,
+		Command:        job.UserCommand,
+		Args:           job.UserArgs,
+		Labels:         job.Labels,
+		Status:         state.Status.String(),
+		ExitCode:       state.ExitCode,
+		CreatedAt:      state.CreatedAt,
+		ExitedAt:       state.ExitedAt,
+		MemoryMB:       state.Limits.MemoryMB,
+		CpuWeight:      state.Limits.CpuWeight,
+		IOWeight:       state.Limits.IOWeight,
+		RestartPolicy:  state.Restart.Policy.String(),
+		MaxRestarts:    state.Restart.MaxRestarts,
+		RestartBackoff: state.Restart.Backoff,
+		Nice:           state.Nice,
+		LogFormat:      "text",
+	}
+	if state.ExitErr != nil {
+		r.Error = state.ExitErr.Error()
+	}
+	if job.LogFormat() == tw.LogJSON {
+		r.LogFormat = "json"
+	}
+	for _, a := range state.Attempts {
+		r.Attempts = append(r.Attempts, recordAttempt(a))
+	}
+	return r
+}
+
+// job recreates the job from the record
+func (r *record) job(restore []tw.Option) (*tw.Job, error) {
+	id, err := uuid.FromString(r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job ID: %w", err)
+	}
+
+	state := tw.JobState{
+		Status:    api.JobStatus(api.JobStatus_value[r.Status]),
+		ExitCode:  r.ExitCode,
+		CreatedAt: r.CreatedAt,
+		ExitedAt:  r.ExitedAt,
+		Limits: &tw.Limits{
+			MemoryMB:  r.MemoryMB,
+			CpuWeight: r.CpuWeight,
+			IOWeight:  r.IOWeight,
+		},
+		Restart: &tw.Restart{
+			Policy:      api.RestartPolicy(api.RestartPolicy_value[r.RestartPolicy]),
+			MaxRestarts: r.MaxRestarts,
+			Backoff:     r.RestartBackoff,
+		},
+		Nice: r.Nice,
+	}
+	if r.Error != "" {
+		state.ExitErr = errors.New(r.Error)
+	}
+	for _, a := range r.Attempts {
+		state.Attempts = append(state.Attempts, tw.Attempt(a))
+	}
+
+	format := tw.LogText
+	if r.LogFormat == "json" {
+		format = tw.LogJSON
+	}
+	options := append(restore[:len(restore):len(restore)],
+		tw.WithUsername(r.User),
+		tw.WithLabels(r.Labels),
+		tw.WithLogFormat(format),
+	)
+
+	return tw.RestoreJob(id, r.Command, r.Args, state, options...)
+}
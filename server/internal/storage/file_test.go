@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// output reads all the retained stdout of the job
+func output(t *testing.T, job *tw.Job) string {
+	ch, cancel := job.StreamStdout(tw.StreamOptions{NoFollow: true})
+	defer cancel()
+
+	var data []byte
+	for chunk := range ch {
+		require.NoError(t, chunk.Err)
+		data = append(data, chunk.Data...)
+	}
+	return string(data)
+}
+
+// waitForOutput waits until the job prints something to stdout
+func waitForOutput(t *testing.T, job *tw.Job) {
+	ch, cancel := job.StreamStdout(tw.StreamOptions{})
+	defer cancel()
+
+	chunk, ok := <-ch
+	require.True(t, ok)
+	require.NoError(t, chunk.Err)
+}
+
+func TestFileRestoresJobs(t *testing.T) {
+	dir := t.TempDir()
+	logs := []tw.Option{tw.WithLogDir(filepath.Join(dir, "logs"))}
+	jobs := filepath.Join(dir, "jobs")
+
+	s, err := NewFileStorage(jobs, logs)
+	require.NoError(t, err)
+
+	options := append(logs, tw.WithUsername("alice"), tw.WithLabels(map[string]string{"app": "web"}))
+	finished, err := tw.NewJob("sh", []string{"-c", "echo hello; exit 3"}, options...)
+	require.NoError(t, err)
+	require.NoError(t, finished.Start())
+	require.NoError(t, s.Put(finished))
+	<-finished.Done()
+
+	running, err := tw.NewJob("sleep", []string{"10"}, logs...)
+	require.NoError(t, err)
+	require.NoError(t, running.Start())
+	require.NoError(t, s.Put(running))
+	defer running.Stop()
+
+	require.NoError(t, s.Close())
+
+	restored, err := NewFileStorage(jobs, logs)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	job, err := restored.Get(finished.ID.String())
+	require.NoError(t, err)
+	state := job.Status()
+	assert.Equal(t, "sh", job.UserCommand)
+	assert.Equal(t, "alice", job.User)
+	assert.Equal(t, map[string]string{"app": "web"}, job.Labels)
+	assert.Equal(t, api.JobStatus_FINISHED, state.Status)
+	assert.Equal(t, 3, state.ExitCode)
+	assert.Equal(t, finished.Status().CreatedAt.UnixNano(), state.CreatedAt.UnixNano())
+	assert.Len(t, state.Attempts, 1)
+	assert.Equal(t, "hello\n", output(t, job))
+
+	job, err = restored.Get(running.ID.String())
+	require.NoError(t, err)
+	state = job.Status()
+	assert.Equal(t, api.JobStatus_STOPPED, state.Status)
+	assert.Equal(t, tw.ErrJobLost, state.ExitErr)
+	assert.Error(t, job.Stop())
+}
+
+func TestFileReplaysJournal(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStorage(dir, nil)
+	require.NoError(t, err)
+
+	job := finishedJob(t)
+	require.NoError(t, s.Put(job))
+	// Closing the old journal without saving the snapshot, as if the
+	// server crashed, in the middle of writing the next entry
+	s.Memory.Close()
+	_, err = s.journal.WriteString(`{"op":"delete","id":`)
+	require.NoError(t, err)
+	require.NoError(t, s.journal.Close())
+
+	restored, err := NewFileStorage(dir, nil)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	jobs, _, err := restored.List(Query{})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, job.ID, jobs[0].ID)
+	assert.Equal(t, api.JobStatus_FINISHED, jobs[0].Status().Status)
+
+	// The journal is replaced by the snapshot once the jobs are restored
+	info, err := os.Stat(filepath.Join(dir, journalName))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+// journalStatuses returns the statuses of the job in the order they were
+// journaled, waiting for the last one to be the expected one
+func journalStatuses(t *testing.T, dir string, id string, last api.JobStatus) []string {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := ioutil.ReadFile(filepath.Join(dir, journalName))
+		require.NoError(t, err)
+
+		var statuses []string
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var e entry
+			require.NoError(t, json.Unmarshal([]byte(line), &e))
+			if e.Job != nil && e.Job.ID == id {
+				statuses = append(statuses, e.Job.Status)
+			}
+		}
+		if len(statuses) > 0 && statuses[len(statuses)-1] == last.String() || time.Now().After(deadline) {
+			return statuses
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFileRecordsStatusChanges(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStorage(dir, nil)
+	require.NoError(t, err)
+
+	job, err := tw.NewJob("sh", []string{"-c", "exit 4"})
+	require.NoError(t, err)
+	require.NoError(t, job.Enqueue())
+	require.NoError(t, s.Put(job))
+	require.NoError(t, job.Start())
+	<-job.Done()
+
+	statuses := journalStatuses(t, dir, job.ID.String(), api.JobStatus_FINISHED)
+	assert.Equal(t, []string{"QUEUED", "ALIVE", "FINISHED"}, statuses)
+
+	// The journal alone is enough to restore the exit code
+	s.Memory.Close()
+	require.NoError(t, s.journal.Close())
+	restored, err := NewFileStorage(dir, nil)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	job, err = restored.Get(job.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, api.JobStatus_FINISHED, job.Status().Status)
+	assert.Equal(t, 4, job.Status().ExitCode)
+}
+
+func TestFileRecordsEvictedJobs(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStorage(dir, nil, WithTTL(1))
+	require.NoError(t, err)
+
+	job := finishedJob(t)
+	require.NoError(t, s.Put(job))
+	s.cleanup()
+	require.NoError(t, s.Close())
+
+	restored, err := NewFileStorage(dir, nil)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	_, err = restored.Get(job.ID.String())
+	assert.IsType(t, &NotFoundError{}, err)
+}
+
+func TestFileAdoptsRunningJobs(t *testing.T) {
+	dir := t.TempDir()
+	shims := []tw.Option{tw.WithShim(filepath.Join(dir, "shims"))}
+	jobs := filepath.Join(dir, "jobs")
+
+	s, err := NewFileStorage(jobs, shims)
+	require.NoError(t, err)
+
+	started, err := tw.NewJob("sh", []string{"-c", "echo started; sleep 1; echo done; exit 2"}, shims...)
+	require.NoError(t, err)
+	require.NoError(t, started.Start())
+	require.NoError(t, s.Put(started))
+
+	killed, err := tw.NewJob("sh", []string{"-c", "echo started; exec sleep 10"}, shims...)
+	require.NoError(t, err)
+	require.NoError(t, killed.Start())
+	require.NoError(t, s.Put(killed))
+
+	// The storage is restored while the jobs are still running,
+	// as if the server was restarted in the meantime
+	waitForOutput(t, started)
+	waitForOutput(t, killed)
+	restored, err := NewFileStorage(jobs, shims)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	job, err := restored.Get(started.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, api.JobStatus_ALIVE, job.Status().Status)
+
+	<-job.Done()
+	state := job.Status()
+	assert.Equal(t, api.JobStatus_FINISHED, state.Status)
+	assert.Equal(t, 2, state.ExitCode)
+	assert.Equal(t, "started\ndone\n", output(t, job))
+
+	job, err = restored.Get(killed.ID.String())
+	require.NoError(t, err)
+	require.NoError(t, job.Stop())
+	assert.Equal(t, api.JobStatus_STOPPED, job.Status().Status)
+	<-killed.Done()
+
+	s.Close()
+}
@@ -1,9 +1,11 @@
 package storage
 
 import (
+	"context"
 	"sync"
 	"time"
 
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
 	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
 )
 
@@ -13,6 +15,14 @@ type Memory struct {
 	// If TTL is specified, then the dead jobs will yet be
 	// presented in the storage for at least that time.
 	ttl time.Duration
+	// onEvict are called for every job evicted due to the TTL
+	onEvict []func(*tw.Job)
+	// stop finishes the cleanup routine, see Close
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	// watchers are notified about the jobs put to the storage
+	watchers map[*watcher]struct{}
 }
 
 // Option is function used for applying configurations to storage
@@ -20,7 +30,9 @@ type Option func(*Memory)
 
 func NewMemStorage(options ...Option) *Memory {
 	s := &Memory{
-		data: make(map[string]*tw.Job),
+		data:     make(map[string]*tw.Job),
+		watchers: make(map[*watcher]struct{}),
+		stop:     make(chan struct{}),
 	}
 
 	for _, opt := range options {
@@ -38,9 +50,16 @@ func NewMemStorage(options ...Option) *Memory {
 		// where N is number of jobs in the storage. This behavior is expected as
 		// we don't care that much if the job lives in memory some time more than
 		// the exact TTL and aim to the simplest implementation possible.
+		ticker := time.NewTicker(cleanupInterval)
 		go func() {
-			for range time.Tick(cleanupInterval) {
-				s.cleanup()
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					s.cleanup()
+				case <-s.stop:
+					return
+				}
 			}
 		}()
 	}
@@ -55,6 +74,21 @@ func WithTTL(ttl time.Duration) Option {
 	}
 }
 
+// WithEvictionHook adds the function called for every job evicted
+// due to the TTL, once the job is removed and released
+func WithEvictionHook(hook func(*tw.Job)) Option {
+	return func(s *Memory) {
+		s.onEvict = append(s.onEvict, hook)
+	}
+}
+
+// Close stops the cleanup routine, the jobs are not evicted afterwards
+func (s *Memory) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
 func (s *Memory) Get(id string) (*tw.Job, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -77,13 +111,125 @@ func (s *Memory) Put(job *tw.Job) error {
 	}
 
 	s.data[id] = job
+	for w := range s.watchers {
+		w.push(job)
+	}
 
 	return nil
 }
 
+// List returns the page of the jobs matching the query
+func (s *Memory) List(q Query) ([]*tw.Job, string, error) {
+	s.mu.RLock()
+	var jobs []*tw.Job
+	for _, job := range s.data {
+		if q.Match(job) {
+			jobs = append(jobs, job)
+		}
+	}
+	s.mu.RUnlock()
+
+	return paginate(jobs, q)
+}
+
+// Watch delivers the jobs put to the storage after the call, until the
+// context is cancelled. The jobs are queued for the slow receivers,
+// so putting the job never waits for anybody
+func (s *Memory) Watch(ctx context.Context) <-chan *tw.Job {
+	w := &watcher{
+		updated: make(chan struct{}, 1),
+	}
+
+	s.mu.Lock()
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+
+	ch := make(chan *tw.Job)
+	go func() {
+		defer close(ch)
+		defer func() {
+			s.mu.Lock()
+			delete(s.watchers, w)
+			s.mu.Unlock()
+		}()
+
+		for {
+			for _, job := range w.pop() {
+				select {
+				case ch <- job:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-w.updated:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// watcher queues the jobs put to the storage for a single receiver
+type watcher struct {
+	mu      sync.Mutex
+	jobs    []*tw.Job
+	updated chan struct{}
+}
+
+func (w *watcher) push(job *tw.Job) {
+	w.mu.Lock()
+	w.jobs = append(w.jobs, job)
+	w.mu.Unlock()
+
+	select {
+	case w.updated <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes all the queued jobs
+func (w *watcher) pop() []*tw.Job {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	jobs := w.jobs
+	w.jobs = nil
+	return jobs
+}
+
 // cleanup frees server memory by calculating the job
 // remaining time to leave based on ExitedAt set upon job
-// termination and server default ttl value
+// termination and server default ttl value. The evicted
+// jobs are to be released, see Job.Release
 func (s *Memory) cleanup() {
-	// TODO not yet implemented.
+	now := time.Now()
+
+	var evicted []*tw.Job
+	s.mu.Lock()
+	for id, job := range s.data {
+		state := job.Status()
+		if state.Status != api.JobStatus_FINISHED && state.Status != api.JobStatus_STOPPED {
+			continue
+		}
+		if state.ExitedAt.IsZero() || now.Before(state.ExitedAt.Add(s.ttl)) {
+			continue
+		}
+
+		delete(s.data, id)
+		evicted = append(evicted, job)
+	}
+	s.mu.Unlock()
+
+	// Releasing the output might take a while, e.g. removing
+	// the files, so nobody waits for the storage meanwhile
+	for _, job := range evicted {
+		job.Release()
+		for _, hook := range s.onEvict {
+			hook(job)
+		}
+	}
 }
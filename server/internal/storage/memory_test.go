@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Jobs are launched by calling the test binary itself,
+	// so it has to behave the same way the server does
+	tw.InternalCallHandle()
+
+	os.Exit(m.Run())
+}
+
+func finishedJob(t *testing.T) *tw.Job {
+	job, err := tw.NewJob("true", nil)
+	require.NoError(t, err)
+	require.NoError(t, job.Start())
+	<-job.Done()
+	return job
+}
+
+func TestCleanupEvictsExpiredJobs(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+
+	var evicted []*tw.Job
+	s := NewMemStorage(
+		WithTTL(ttl),
+		WithEvictionHook(func(job *tw.Job) {
+			evicted = append(evicted, job)
+		}),
+	)
+	defer s.Close()
+
+	finished := finishedJob(t)
+	require.NoError(t, s.Put(finished))
+	assert.False(t, finished.Status().ExitedAt.IsZero())
+
+	running, err := tw.NewJob("sleep", []string{"1"})
+	require.NoError(t, err)
+	require.NoError(t, running.Start())
+	require.NoError(t, s.Put(running))
+
+	// Nothing is expired yet
+	s.cleanup()
+	assert.Empty(t, evicted)
+
+	time.Sleep(ttl)
+	s.cleanup()
+	assert.Equal(t, []*tw.Job{finished}, evicted)
+
+	_, err = s.Get(finished.ID.String())
+	assert.IsType(t, &NotFoundError{}, err)
+	_, err = s.Get(running.ID.String())
+	assert.NoError(t, err)
+
+	<-running.Done()
+}
+
+func TestCloseStopsCleanup(t *testing.T) {
+	s := NewMemStorage(WithTTL(time.Millisecond))
+	s.Close()
+	// Closing twice is fine
+	s.Close()
+
+	select {
+	case <-s.stop:
+	default:
+		t.Fatal("expected the cleanup routine to be stopped")
+	}
+}
+
+func TestListFiltersAndPages(t *testing.T) {
+	s := NewMemStorage()
+	defer s.Close()
+
+	var ids []string
+	for _, user := range []string{"alice", "bob", "alice", "alice"} {
+		job, err := tw.NewJob("echo", []string{user}, tw.WithUsername(user))
+		require.NoError(t, err)
+		require.NoError(t, s.Put(job))
+		ids = append(ids, job.ID.String())
+	}
+
+	listed := func(jobs []*tw.Job) []string {
+		var ids []string
+		for _, job := range jobs {
+			ids = append(ids, job.ID.String())
+		}
+		return ids
+	}
+
+	jobs, next, err := s.List(Query{Filter: Filter{Owner: "alice"}, PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ids[3], ids[2]}, listed(jobs))
+	assert.NotEmpty(t, next)
+
+	jobs, next, err = s.List(Query{Filter: Filter{Owner: "alice"}, PageSize: 2, PageToken: next})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ids[0]}, listed(jobs))
+	assert.Empty(t, next)
+
+	jobs, _, err = s.List(Query{Filter: Filter{Command: "echo b"}, Order: OldestFirst})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ids[1]}, listed(jobs))
+
+	_, _, err = s.List(Query{PageToken: "garbage"})
+	assert.IsType(t, &InvalidPageTokenError{}, err)
+}
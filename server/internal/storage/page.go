@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+)
+
+// cursor is the position of the job in the listing, the jobs are
+// ordered by their creation time, the IDs settle the ties
+type cursor struct {
+	createdAt time.Time
+	id        string
+}
+
+func jobCursor(job *tw.Job) cursor {
+	return cursor{
+		createdAt: job.Status().CreatedAt,
+		id:        job.ID.String(),
+	}
+}
+
+func (c cursor) before(other cursor) bool {
+	if !c.createdAt.Equal(other.createdAt) {
+		return c.createdAt.Before(other.createdAt)
+	}
+	return c.id < other.id
+}
+
+// The page token refers to the last job of the previous page rather than
+// to its number, so the jobs created or evicted in between the requests
+// don't shift the following pages
+func (c cursor) token() string {
+	raw := fmt.Sprintf("%d:%s", c.createdAt.UnixNano(), c.id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseToken(token string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor{}, &InvalidPageTokenError{token}
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return cursor{}, &InvalidPageTokenError{token}
+	}
+	nsec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return cursor{}, &InvalidPageTokenError{token}
+	}
+	return cursor{createdAt: time.Unix(0, nsec), id: parts[1]}, nil
+}
+
+// paginate orders the jobs matching the query and cuts the requested page
+func paginate(jobs []*tw.Job, q Query) ([]*tw.Job, string, error) {
+	var after *cursor
+	if q.PageToken != "" {
+		c, err := parseToken(q.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		after = &c
+	}
+
+	cursors := make(map[*tw.Job]cursor, len(jobs))
+	for _, job := range jobs {
+		cursors[job] = jobCursor(job)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		if q.Order == OldestFirst {
+			return cursors[jobs[i]].before(cursors[jobs[k]])
+		}
+		return cursors[jobs[k]].before(cursors[jobs[i]])
+	})
+
+	if after != nil {
+		i := sort.Search(len(jobs), func(i int) bool {
+			if q.Order == OldestFirst {
+				return after.before(cursors[jobs[i]])
+			}
+			return cursors[jobs[i]].before(*after)
+		})
+		jobs = jobs[i:]
+	}
+
+	if q.PageSize <= 0 || len(jobs) <= q.PageSize {
+		return jobs, "", nil
+	}
+	jobs = jobs[:q.PageSize]
+	return jobs, cursors[jobs[len(jobs)-1]].token(), nil
+}
@@ -1,9 +1,89 @@
 package storage
 
-import "github.com/spirifoxy/teleworker/pkg/teleworker"
+import (
+	"context"
+	"strings"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	"github.com/spirifoxy/teleworker/pkg/teleworker"
+)
 
 // Storage is an inner storage base interface
 type Storage interface {
 	Get(id string) (*teleworker.Job, error)
 	Put(*teleworker.Job) error
+	// List returns the page of the jobs matching the query along
+	// with the token of the next page, empty if it is the last one
+	List(q Query) ([]*teleworker.Job, string, error)
+	// Watch delivers the jobs put to the storage after
+	// the call, until the context is cancelled
+	Watch(ctx context.Context) <-chan *teleworker.Job
+}
+
+// Filter selects the jobs, the zero values match any job
+type Filter struct {
+	Owner string
+	// Statuses match the jobs having any of them
+	Statuses []api.JobStatus
+	// Labels match the jobs having all of them
+	Labels map[string]string
+	// Command is the substring of the command line
+	Command string
+	// CreatedAfter and CreatedBefore limit the creation time
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Match returns whether the job passes the filter
+func (f *Filter) Match(job *teleworker.Job) bool {
+	if f.Owner != "" && job.User != f.Owner {
+		return false
+	}
+	if !job.HasLabels(f.Labels) {
+		return false
+	}
+	if f.Command != "" {
+		line := strings.Join(append([]string{job.UserCommand}, job.UserArgs...), " ")
+		if !strings.Contains(line, f.Command) {
+			return false
+		}
+	}
+
+	state := job.Status()
+	if !f.CreatedAfter.IsZero() && state.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !state.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, status := range f.Statuses {
+		if state.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Order is the order the jobs are listed in
+type Order int
+
+const (
+	// NewestFirst lists the recently created jobs first
+	NewestFirst Order = iota
+	// OldestFirst lists the jobs in the order they were created
+	OldestFirst
+)
+
+// Query describes the page of the jobs to be listed
+type Query struct {
+	Filter
+	Order Order
+	// PageSize is the maximum number of the jobs listed, 0 means unlimited
+	PageSize int
+	// PageToken is the token of the page returned along with the previous one
+	PageToken string
 }
@@ -0,0 +1,56 @@
+package workflow
+
+import "fmt"
+
+type NotFoundError struct {
+	id string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("workflow %s was not found", e.id)
+}
+
+type EmptyError struct{}
+
+func (e *EmptyError) Error() string {
+	return "workflow has no steps"
+}
+
+type UnnamedStepError struct{}
+
+func (e *UnnamedStepError) Error() string {
+	return "every step must have a name"
+}
+
+type MissingCommandError struct {
+	step string
+}
+
+func (e *MissingCommandError) Error() string {
+	return fmt.Sprintf("step %s has no command to run", e.step)
+}
+
+type DuplicateStepError struct {
+	name string
+}
+
+func (e *DuplicateStepError) Error() string {
+	return fmt.Sprintf("step %s is defined more than once", e.name)
+}
+
+type UnknownDependencyError struct {
+	step       string
+	dependency string
+}
+
+func (e *UnknownDependencyError) Error() string {
+	return fmt.Sprintf("step %s depends on unknown step %s", e.step, e.dependency)
+}
+
+type CycleError struct {
+	step string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("step %s is a part of the dependency cycle", e.step)
+}
@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"sync"
+	"time"
+)
+
+// Memory keeps all the submitted workflows
+type Memory struct {
+	mu   sync.RWMutex
+	data map[string]*Workflow
+	// If TTL is specified, the finished workflows are
+	// kept in the storage for at least that time
+	ttl time.Duration
+	// stop finishes the cleanup routine, see Close
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// Option is function used for applying configurations to storage
+type Option func(*Memory)
+
+func NewMemStorage(options ...Option) *Memory {
+	s := &Memory{
+		data: make(map[string]*Workflow),
+		stop: make(chan struct{}),
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	if s.ttl > 0 {
+		// Same as for the jobs, the workflows might
+		// stay a bit longer than the exact TTL
+		const cleanupInterval = 1 * time.Minute
+
+		ticker := time.NewTicker(cleanupInterval)
+		go func() {
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					s.cleanup()
+				case <-s.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	return s
+}
+
+// WithTTL is used for specifying the finished workflows ttl
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Memory) {
+		s.ttl = ttl
+	}
+}
+
+// Close stops the cleanup routine, the workflows are not evicted afterwards
+func (s *Memory) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *Memory) Get(id string) (*Workflow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	w, ok := s.data[id]
+	if !ok {
+		return nil, &NotFoundError{id}
+	}
+
+	return w, nil
+}
+
+func (s *Memory) Put(w *Workflow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[w.ID.String()] = w
+}
+
+// cleanup evicts the workflows finished longer than the TTL ago.
+// The jobs of the steps are evicted from their own storage
+func (s *Memory) cleanup() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, w := range s.data {
+		w.mu.RLock()
+		finishedAt := w.finishedAt
+		w.mu.RUnlock()
+
+		if finishedAt.IsZero() || now.Before(finishedAt.Add(s.ttl)) {
+			continue
+		}
+		delete(s.data, id)
+	}
+}
@@ -0,0 +1,309 @@
+package workflow
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+)
+
+// Launcher starts the job described by the request
+// on behalf of the workflow owner
+type Launcher func(req *api.StartRequest) (*tw.Job, error)
+
+type Step struct {
+	Name      string
+	Request   *api.StartRequest
+	DependsOn []string
+	Policy    api.DependencyPolicy
+
+	state api.WorkflowState
+	job   *tw.Job
+	err   error
+}
+
+// StepState is a snapshot of the step state
+type StepState struct {
+	Name     string
+	State    api.WorkflowState
+	JobID    string
+	ExitCode int
+	Err      error
+}
+
+// Workflow is a set of jobs with dependencies between them. Every job is
+// started as soon as the jobs it depends on are finished, so the jobs
+// without dependencies between each other are run in parallel
+type Workflow struct {
+	ID   uuid.UUID
+	User string
+
+	mu     sync.RWMutex
+	steps  []*Step
+	byName map[string]*Step
+	state  api.WorkflowState
+	// finishedAt is the moment all the steps were over
+	finishedAt time.Time
+
+	done chan struct{}
+}
+
+// New validates the steps and creates the workflow, which is yet to be run
+func New(user string, steps []*Step) (*Workflow, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error generating uuid: %w", err)
+	}
+
+	w := &Workflow{
+		ID:     id,
+		User:   user,
+		steps:  steps,
+		byName: make(map[string]*Step, len(steps)),
+		state:  api.WorkflowState_PENDING,
+		done:   make(chan struct{}),
+	}
+
+	err = w.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Workflow) validate() error {
+	if len(w.steps) == 0 {
+		return &EmptyError{}
+	}
+
+	for _, step := range w.steps {
+		if step.Name == "" {
+			return &UnnamedStepError{}
+		}
+		if step.Request.GetCommand() == "" {
+			return &MissingCommandError{step.Name}
+		}
+		if _, ok := w.byName[step.Name]; ok {
+			return &DuplicateStepError{step.Name}
+		}
+		w.byName[step.Name] = step
+	}
+
+	for _, step := range w.steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := w.byName[dep]; !ok {
+				return &UnknownDependencyError{step.Name, dep}
+			}
+		}
+	}
+
+	return w.checkCycles()
+}
+
+// checkCycles makes sure the dependencies form a DAG, otherwise
+// some of the steps would wait for each other forever
+func (w *Workflow) checkCycles() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(w.steps))
+
+	var visit func(step *Step) error
+	visit = func(step *Step) error {
+		switch marks[step.Name] {
+		case visiting:
+			return &CycleError{step.Name}
+		case visited:
+			return nil
+		}
+
+		marks[step.Name] = visiting
+		for _, dep := range step.DependsOn {
+			if err := visit(w.byName[dep]); err != nil {
+				return err
+			}
+		}
+		marks[step.Name] = visited
+		return nil
+	}
+
+	for _, step := range w.steps {
+		if err := visit(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run starts the steps in the background as their dependencies are finished
+func (w *Workflow) Run(launch Launcher) {
+	w.mu.Lock()
+	w.state = api.WorkflowState_RUNNING
+	w.mu.Unlock()
+
+	go w.run(launch)
+}
+
+func (w *Workflow) run(launch Launcher) {
+	finished := make(chan *Step)
+	running := 0
+
+	for {
+		w.mu.Lock()
+		ready := w.takeReady()
+		if len(ready) == 0 && running == 0 {
+			// Nothing is running and nothing can be started,
+			// so every step has reached its final state
+			w.state = w.result()
+			w.finishedAt = time.Now()
+			w.mu.Unlock()
+			close(w.done)
+			return
+		}
+		w.mu.Unlock()
+
+		// Starting the job might take a while, e.g. waiting for the queue,
+		// so the steps are launched without holding up the status requests
+		for _, step := range ready {
+			if w.start(step, launch, finished) {
+				running++
+			}
+		}
+		if len(ready) > 0 {
+			// The steps failed to start might let
+			// their dependents be started or cancelled
+			continue
+		}
+
+		step := <-finished
+		running--
+
+		w.mu.Lock()
+		state := step.job.Status()
+		if state.Status == api.JobStatus_FINISHED && state.ExitCode == 0 {
+			step.state = api.WorkflowState_SUCCEEDED
+		} else {
+			step.state = api.WorkflowState_FAILED
+		}
+		w.mu.Unlock()
+	}
+}
+
+// takeReady marks all the pending steps whose dependencies are finished
+// running and returns them to be started, while the ones which are not
+// going to be started anymore are cancelled. Should be called holding the lock
+func (w *Workflow) takeReady() []*Step {
+	var ready []*Step
+
+	// Cancelling a step might make its dependents cancelled
+	// as well, so keep going until nothing changes
+	for changed := true; changed; {
+		changed = false
+
+		for _, step := range w.steps {
+			if step.state != api.WorkflowState_PENDING {
+				continue
+			}
+
+			ok, cancel := w.resolve(step)
+			if cancel {
+				step.state = api.WorkflowState_CANCELLED
+				changed = true
+				continue
+			}
+			if !ok {
+				continue
+			}
+
+			step.state = api.WorkflowState_RUNNING
+			ready = append(ready, step)
+		}
+	}
+
+	return ready
+}
+
+// start launches the job of the step taken by takeReady and reports the
+// step to finished once the job is over. Returns whether the job was started
+func (w *Workflow) start(step *Step, launch Launcher, finished chan<- *Step) bool {
+	job, err := launch(step.Request)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err != nil {
+		step.state = api.WorkflowState_FAILED
+		step.err = err
+		return false
+	}
+
+	step.job = job
+	go func() {
+		<-job.Done()
+		finished <- step
+	}()
+	return true
+}
+
+// resolve checks the step dependencies and returns whether the step is
+// ready to be started or it is not going to be started at all
+func (w *Workflow) resolve(step *Step) (ready bool, cancel bool) {
+	succeeded := true
+	for _, name := range step.DependsOn {
+		switch w.byName[name].state {
+		case api.WorkflowState_PENDING, api.WorkflowState_RUNNING:
+			return false, false
+		case api.WorkflowState_SUCCEEDED:
+		default:
+			succeeded = false
+		}
+	}
+
+	if !succeeded && step.Policy == api.DependencyPolicy_ON_SUCCESS {
+		return false, true
+	}
+	return true, false
+}
+
+// result calculates the final state of the workflow
+func (w *Workflow) result() api.WorkflowState {
+	for _, step := range w.steps {
+		if step.state != api.WorkflowState_SUCCEEDED {
+			return api.WorkflowState_FAILED
+		}
+	}
+	return api.WorkflowState_SUCCEEDED
+}
+
+// Status returns the state of the workflow along with the states of its steps
+func (w *Workflow) Status() (api.WorkflowState, []StepState) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	steps := make([]StepState, 0, len(w.steps))
+	for _, step := range w.steps {
+		state := StepState{
+			Name:  step.Name,
+			State: step.state,
+			Err:   step.err,
+		}
+		if step.job != nil {
+			state.JobID = step.job.ID.String()
+			state.ExitCode = step.job.Status().ExitCode
+		}
+		steps = append(steps, state)
+	}
+
+	return w.state, steps
+}
+
+// Done returns a channel that is closed when all the steps are over
+func (w *Workflow) Done() <-chan struct{} {
+	return w.done
+}
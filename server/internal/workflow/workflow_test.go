@@ -0,0 +1,153 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func step(name string, deps ...string) *Step {
+	return &Step{
+		Name:      name,
+		Request:   &api.StartRequest{Command: "true"},
+		DependsOn: deps,
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []*Step
+		err   error
+	}{
+		{
+			name:  "valid dag",
+			steps: []*Step{step("extract"), step("a", "extract"), step("b", "extract"), step("load", "a", "b")},
+		},
+		{
+			name: "empty",
+			err:  &EmptyError{},
+		},
+		{
+			name:  "unnamed step",
+			steps: []*Step{step("")},
+			err:   &UnnamedStepError{},
+		},
+		{
+			name:  "missing command",
+			steps: []*Step{{Name: "extract"}},
+			err:   &MissingCommandError{"extract"},
+		},
+		{
+			name:  "duplicate step",
+			steps: []*Step{step("extract"), step("extract")},
+			err:   &DuplicateStepError{"extract"},
+		},
+		{
+			name:  "unknown dependency",
+			steps: []*Step{step("load", "transform")},
+			err:   &UnknownDependencyError{"load", "transform"},
+		},
+		{
+			name:  "self dependency",
+			steps: []*Step{step("load", "load")},
+			err:   &CycleError{"load"},
+		},
+		{
+			name:  "cycle",
+			steps: []*Step{step("extract", "load"), step("transform", "extract"), step("load", "transform")},
+			err:   &CycleError{"extract"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New("user", tt.steps)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	w, err := New("user", []*Step{
+		step("a"),
+		step("b"),
+		step("c", "a", "b"),
+	})
+	assert.Nil(t, err)
+	c := w.byName["c"]
+
+	ready, cancel := w.resolve(c)
+	assert.False(t, ready)
+	assert.False(t, cancel)
+
+	w.byName["a"].state = api.WorkflowState_SUCCEEDED
+	w.byName["b"].state = api.WorkflowState_RUNNING
+	ready, cancel = w.resolve(c)
+	assert.False(t, ready)
+	assert.False(t, cancel)
+
+	w.byName["b"].state = api.WorkflowState_SUCCEEDED
+	ready, cancel = w.resolve(c)
+	assert.True(t, ready)
+	assert.False(t, cancel)
+
+	w.byName["b"].state = api.WorkflowState_FAILED
+	ready, cancel = w.resolve(c)
+	assert.False(t, ready)
+	assert.True(t, cancel)
+
+	c.Policy = api.DependencyPolicy_ON_COMPLETION
+	ready, cancel = w.resolve(c)
+	assert.True(t, ready)
+	assert.False(t, cancel)
+}
+
+func TestRunLaunchesWithoutLock(t *testing.T) {
+	w, err := New("user", []*Step{step("a"), step("b", "a")})
+	require.NoError(t, err)
+
+	// The status is available while the step is being launched
+	launched := errors.New("not launched")
+	go w.Run(func(req *api.StartRequest) (*tw.Job, error) {
+		state, steps := w.Status()
+		assert.Equal(t, api.WorkflowState_RUNNING, state)
+		assert.Equal(t, api.WorkflowState_RUNNING, steps[0].State)
+		return nil, launched
+	})
+
+	select {
+	case <-w.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("workflow is not finished")
+	}
+	state, steps := w.Status()
+	assert.Equal(t, api.WorkflowState_FAILED, state)
+	assert.Equal(t, api.WorkflowState_FAILED, steps[0].State)
+	assert.Equal(t, api.WorkflowState_CANCELLED, steps[1].State)
+}
+
+func TestMemoryEvictsFinishedWorkflows(t *testing.T) {
+	s := NewMemStorage(WithTTL(time.Minute))
+	defer s.Close()
+
+	running, err := New("user", []*Step{step("a")})
+	require.NoError(t, err)
+	s.Put(running)
+
+	finished, err := New("user", []*Step{step("a")})
+	require.NoError(t, err)
+	finished.finishedAt = time.Now().Add(-2 * time.Minute)
+	s.Put(finished)
+
+	s.cleanup()
+	_, err = s.Get(running.ID.String())
+	assert.NoError(t, err)
+	_, err = s.Get(finished.ID.String())
+	assert.IsType(t, &NotFoundError{}, err)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/spirifoxy/teleworker/server/internal/storage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultPageSize is the number of the jobs listed if not requested otherwise
+	defaultPageSize = 50
+	// maxPageSize limits the size of a single response
+	maxPageSize = 500
+)
+
+// ListJobs lists the jobs matching the request filters page by page.
+// The users see their own jobs only, unless they are the admins
+func (s *TWServer) ListJobs(ctx context.Context, req *api.ListJobsRequest) (*api.ListJobsResponse, error) {
+	user, ok := UsernameFromCtx(ctx)
+	if !ok {
+		return nil, &UnauthorizedReq{}
+	}
+
+	owner := req.GetOwner()
+	if owner == "" && !req.GetAllUsers() {
+		owner = user.Name
+	}
+	if owner != user.Name && !s.admins[user.Name] {
+		return nil, &AccessDenied{}
+	}
+
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	q := storage.Query{
+		Filter: storage.Filter{
+			Owner:    owner,
+			Statuses: req.GetStatuses(),
+			Labels:   req.GetLabels(),
+			Command:  req.GetCommand(),
+		},
+		PageSize:  pageSize,
+		PageToken: req.GetPageToken(),
+	}
+	if req.GetCreatedAfter() != nil {
+		q.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		q.CreatedBefore = req.GetCreatedBefore().AsTime()
+	}
+	if req.GetOrder() == api.SortOrder_OLDEST_FIRST {
+		q.Order = storage.OldestFirst
+	}
+
+	jobs, next, err := s.store.List(q)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &api.ListJobsResponse{
+		Jobs:          make([]*api.JobSummary, 0, len(jobs)),
+		NextPageToken: next,
+	}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, jobSummary(job))
+	}
+	return resp, nil
+}
+
+func jobSummary(job *tw.Job) *api.JobSummary {
+	state := job.Status()
+
+	summary := &api.JobSummary{
+		JobId:     job.ID.String(),
+		Owner:     job.User,
+		Command:   job.UserCommand,
+		Args:      job.UserArgs,
+		Status:    state.Status,
+		ExitCode:  int32(state.ExitCode),
+		CreatedAt: timestamppb.New(state.CreatedAt),
+		Labels:    job.Labels,
+	}
+	if !state.ExitedAt.IsZero() {
+		summary.ExitedAt = timestamppb.New(state.ExitedAt)
+	}
+	return summary
+}
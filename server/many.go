@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/spirifoxy/teleworker/server/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// nameLabel is the label naming the job in the streams of several jobs
+const nameLabel = "name"
+
+// StreamMany streams the output of several jobs together, every response
+// carrying a single line of one of them. If the stream follows the output,
+// the jobs of the user matching the selector are added once they start
+func (s *TWServer) StreamMany(req *api.StreamManyRequest, stream api.TeleWorker_StreamManyServer) error {
+	user, ok := UsernameFromCtx(stream.Context())
+	if !ok {
+		return &UnauthorizedReq{}
+	}
+	selector := req.GetSelector()
+	if len(req.GetJobIds()) == 0 && len(selector) == 0 {
+		return &NoJobsSelected{}
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	// Start watching before selecting the jobs,
+	// so no job started in between is missed
+	var started <-chan *tw.Job
+	if following(req) && len(selector) > 0 {
+		started = s.store.Watch(ctx)
+	}
+
+	jobs := make([]*tw.Job, 0, len(req.GetJobIds()))
+	for _, id := range req.GetJobIds() {
+		job, err := s.store.Get(id)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	if len(selector) > 0 {
+		selected, _, err := s.store.List(storage.Query{
+			Filter: storage.Filter{Owner: user.Name, Labels: selector},
+			Order:  storage.OldestFirst,
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, selected...)
+	}
+
+	out := make(chan *api.StreamManyResponse)
+	finished := make(chan error)
+	streamed := make(map[string]bool)
+	active := 0
+	add := func(job *tw.Job) {
+		id := job.ID.String()
+		if streamed[id] {
+			return
+		}
+		streamed[id] = true
+		active++
+
+		go func() {
+			err := streamJob(ctx, job, req, out)
+			select {
+			case finished <- err:
+			case <-ctx.Done():
+			}
+		}()
+	}
+	for _, job := range jobs {
+		add(job)
+	}
+
+	for active > 0 || started != nil {
+		select {
+		case resp := <-out:
+			err := stream.Send(resp)
+			if err != nil {
+				return err
+			}
+		case err := <-finished:
+			if err != nil {
+				return status.Error(codes.ResourceExhausted, err.Error())
+			}
+			active--
+		case job, ok := <-started:
+			if !ok {
+				return nil
+			}
+			if job.User == user.Name && job.HasLabels(selector) {
+				add(job)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// following returns whether the output is followed, which is what
+// the clients not aware of the flag expect
+func following(req *api.StreamManyRequest) bool {
+	return req.Follow == nil || req.GetFollow()
+}
+
+// streamJob sends the lines of the job output to the channel until
+// either the output is over or the context is cancelled. Returns the
+// error if the job stream was interrupted, e.g. it didn't keep up
+func streamJob(ctx context.Context, job *tw.Job, req *api.StreamManyRequest, out chan<- *api.StreamManyResponse) error {
+	opts := tw.StreamOptions{
+		TailLines: int(req.GetTailLines()),
+		NoFollow:  !following(req),
+	}
+
+	var ch <-chan tw.Chunk
+	var streamCancel context.CancelFunc
+	source := api.OutputSource_STDOUT
+	if req.GetCombined() {
+		ch, streamCancel = job.StreamCombined(opts)
+	} else if req.GetStreamErrors() {
+		source = api.OutputSource_STDERR
+		ch, streamCancel = job.StreamStderr(opts)
+	} else {
+		ch, streamCancel = job.StreamStdout(opts)
+	}
+	defer streamCancel()
+
+	id := job.ID.String()
+	name := jobName(job)
+	// The lines of different jobs must not be mixed, so
+	// the stream is split into the lines by the filter
+	for chunk := range tw.FilterLines(ctx, ch, tw.LineFilter{}) {
+		if chunk.Err != nil {
+			return chunk.Err
+		}
+
+		resp := &api.StreamManyResponse{
+			JobId:     id,
+			JobName:   name,
+			OutStream: chunk.Data,
+			Offset:    chunk.Offset,
+			Truncated: chunk.Truncated,
+			Source:    source,
+		}
+		if req.GetCombined() && chunk.Source == tw.SourceStderr {
+			resp.Source = api.OutputSource_STDERR
+		}
+		if !chunk.Time.IsZero() {
+			resp.Timestamp = timestamppb.New(chunk.Time)
+		}
+
+		select {
+		case out <- resp:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// jobName returns the short name of the job, which is the value of its
+// name label if there is one, the beginning of the job ID otherwise
+func jobName(job *tw.Job) string {
+	if name := job.Labels[nameLabel]; name != "" {
+		return name
+	}
+	return job.ID.String()[:8]
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
 	"time"
 
 	api "github.com/spirifoxy/teleworker/internal/api/v1"
 	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
 	"github.com/spirifoxy/teleworker/server/internal/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UnauthorizedReq struct{}
@@ -21,6 +31,45 @@ func (e *AccessDenied) Error() string {
 	return "you have no rights to perform that operation"
 }
 
+type MissingJobID struct{}
+
+func (e *MissingJobID) Error() string {
+	return "the first message of the stream must contain the job ID"
+}
+
+type MissingStartReq struct{}
+
+func (e *MissingStartReq) Error() string {
+	return "the first message of the stream must contain the job configuration"
+}
+
+type PriorityDenied struct{}
+
+func (e *PriorityDenied) Error() string {
+	return "you have no rights to request elevated priority"
+}
+
+type NotStructuredLog struct{}
+
+func (e *NotStructuredLog) Error() string {
+	return "the job output is not in JSON format, the fields can't be filtered"
+}
+
+type NoJobsSelected struct{}
+
+func (e *NoJobsSelected) Error() string {
+	return "either the job IDs or the label selector must be provided"
+}
+
+type InvalidFilter struct {
+	expr string
+	err  error
+}
+
+func (e *InvalidFilter) Error() string {
+	return fmt.Sprintf("invalid filter expression %s: %v", e.expr, e.err)
+}
+
 var UsernameFromCtx = auth.UsernameFromCtx
 
 func (s *TWServer) Start(ctx context.Context, req *api.StartRequest) (*api.StartResponse, error) {
@@ -29,6 +78,103 @@ func (s *TWServer) Start(ctx context.Context, req *api.StartRequest) (*api.Start
 		return nil, &UnauthorizedReq{}
 	}
 
+	job, err := s.startJob(user, req, inputOptions(req)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.StartResponse{
+		JobId: job.ID.String(),
+	}, nil
+}
+
+// JobIDHeader is the header StartWithInput sends the job ID in
+// as soon as the job is created, before the input is over
+const JobIDHeader = "job-id"
+
+// StartWithInput starts the job configured by the first message of the stream
+// and then keeps passing the rest of the stream to the job stdin. The input
+// is not read while the job is queued, so the client is held back until the
+// job starts, or until it is stopped, which drops the rest of the input
+func (s *TWServer) StartWithInput(stream api.TeleWorker_StartWithInputServer) error {
+	user, ok := UsernameFromCtx(stream.Context())
+	if !ok {
+		return &UnauthorizedReq{}
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	req := first.GetStart()
+	if req == nil {
+		return &MissingStartReq{}
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	job, err := s.startJob(user, req, tw.WithStdin(stdinReader))
+	if err != nil {
+		return err
+	}
+
+	// The client might want to watch the job while still sending the input
+	err = stream.SendHeader(metadata.Pairs(JobIDHeader, job.ID.String()))
+	if err != nil {
+		stdinWriter.CloseWithError(err)
+		return err
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			stdinWriter.CloseWithError(err)
+			return err
+		}
+
+		_, err = stdinWriter.Write(msg.GetStdin())
+		if err != nil {
+			// The job doesn't accept the input anymore, there is
+			// no point in reading the rest of the stream
+			break
+		}
+	}
+	stdinWriter.Close()
+
+	return stream.SendAndClose(&api.StartResponse{
+		JobId: job.ID.String(),
+	})
+}
+
+// inputOptions returns the options passing the input and the terminal
+// requested to the job. Every job started by the same request, e.g. by
+// the schedule, gets the input of its own
+func inputOptions(req *api.StartRequest) []tw.Option {
+	var options []tw.Option
+	if stdin := req.GetStdin(); len(stdin) > 0 {
+		options = append(options, tw.WithStdin(bytes.NewReader(stdin)))
+	}
+	if req.GetTty() {
+		options = append(options, tw.WithTTY())
+	}
+	return options
+}
+
+// checkPriority makes sure the user is allowed to request the priority
+// of the job. The jobs started later, e.g. by the schedules, are checked
+// in advance, so they are not accepted only to fail on every launch
+func (s *TWServer) checkPriority(user *auth.User, req *api.StartRequest) error {
+	if req.GetPriority() < 0 && !s.elevated[user.Name] {
+		return &PriorityDenied{}
+	}
+	return nil
+}
+
+// startJob creates the job described by the start request,
+// submits it to the queue and puts it to the storage
+func (s *TWServer) startJob(user *auth.User, req *api.StartRequest, options ...tw.Option) (*tw.Job, error) {
 	command := req.GetCommand()
 	args := req.GetArgs()
 	limits := &tw.Limits{
@@ -36,19 +182,39 @@ func (s *TWServer) Start(ctx context.Context, req *api.StartRequest) (*api.Start
 		CpuWeight: int(req.GetCpuWeight()),
 		IOWeight:  int(req.GetIoWeight()),
 	}
+	restart := &tw.Restart{
+		Policy:      req.GetRestartPolicy(),
+		MaxRestarts: int(req.GetMaxRestarts()),
+		Backoff:     time.Duration(req.GetRestartBackoffMs()) * time.Millisecond,
+	}
 
-	var err error
-	job, err := tw.NewJob(
-		command,
-		args,
+	priority := int(req.GetPriority())
+	if err := s.checkPriority(user, req); err != nil {
+		return nil, err
+	}
+
+	options = append(options, s.logOptions...)
+	if s.shimDir != "" {
+		options = append(options, tw.WithShim(s.shimDir))
+	}
+	options = append(options,
+		tw.WithLogFormat(logFormat(req.GetLogFormat())),
+		tw.WithLabels(req.GetLabels()),
 		tw.WithLimits(limits),
+		tw.WithRestart(restart),
+		tw.WithPriority(priority),
 		tw.WithUsername(user.Name),
 	)
+
+	var err error
+	job, err := tw.NewJob(command, args, options...)
 	if err != nil {
 		return nil, err
 	}
 
-	err = job.Start()
+	// The job might not be started right away
+	// if there are too many jobs running already
+	err = s.queue.Submit(job, priority)
 	if err != nil {
 		return nil, err
 	}
@@ -58,9 +224,7 @@ func (s *TWServer) Start(ctx context.Context, req *api.StartRequest) (*api.Start
 		return nil, err
 	}
 
-	return &api.StartResponse{
-		JobId: job.ID.String(),
-	}, nil
+	return job, nil
 }
 
 func (s *TWServer) Stop(ctx context.Context, req *api.StopRequest) (*api.StopResponse, error) {
@@ -97,13 +261,37 @@ func (s *TWServer) Status(ctx context.Context, req *api.StatusRequest) (*api.Sta
 	}
 
 	state := job.Status()
-	return &api.StatusResponse{
+
+	attempts := make([]*api.Attempt, 0, len(state.Attempts))
+	for _, a := range state.Attempts {
+		attempt := &api.Attempt{
+			ExitCode:  int32(a.ExitCode),
+			StartedAt: timestamppb.New(a.StartedAt),
+		}
+		if !a.ExitedAt.IsZero() {
+			attempt.ExitedAt = timestamppb.New(a.ExitedAt)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	resp := &api.StatusResponse{
 		Status:             state.Status,
 		MemoryLimitMb:      int32(state.Limits.MemoryMB),
 		CpuLimitPercentage: int32(state.Limits.CpuWeight),
 		IoLimitPercentage:  int32(state.Limits.IOWeight),
 		ExitCode:           int32(state.ExitCode),
-	}, nil
+		Attempt:            int32(len(state.Attempts)),
+		Attempts:           attempts,
+		QueuePosition:      int32(s.queue.Position(job)),
+		Nice:               int32(state.Nice),
+		LogParseErrors:     state.LogParseErrors,
+		Labels:             job.Labels,
+		CreatedAt:          timestamppb.New(state.CreatedAt),
+	}
+	if state.LastLogParseError != nil {
+		resp.LastLogParseError = state.LastLogParseError.Error()
+	}
+	return resp, nil
 }
 
 func (s *TWServer) Stream(req *api.StreamRequest, stream api.TeleWorker_StreamServer) error {
@@ -113,15 +301,48 @@ func (s *TWServer) Stream(req *api.StreamRequest, stream api.TeleWorker_StreamSe
 		return err
 	}
 
-	var streamCh <-chan []byte
+	filter, err := lineFilter(req)
+	if err != nil {
+		return err
+	}
+	if filter != nil && (len(filter.Where) > 0 || len(filter.Fields) > 0) && job.LogFormat() != tw.LogJSON {
+		return &NotStructuredLog{}
+	}
+
+	var streamCh <-chan tw.Chunk
 	var streamCancel context.CancelFunc
 
-	if req.StreamErrors {
-		streamCh, streamCancel = job.StreamStderr()
+	opts := tw.StreamOptions{
+		From:      req.GetFromOffset(),
+		FromSeq:   req.GetFromSeq(),
+		TailLines: int(req.GetTailLines()),
+		TailBytes: req.GetTailBytes(),
+		// The clients not aware of the flag expect the output to be followed
+		NoFollow: req.Follow != nil && !req.GetFollow(),
+	}
+	if req.GetSince() != nil {
+		opts.Since = req.GetSince().AsTime()
+	}
+	if req.GetUntil() != nil {
+		opts.Until = req.GetUntil().AsTime()
+	}
+	// source is the output all the chunks come from, unless both are streamed
+	source := api.OutputSource_STDOUT
+	if req.GetCombined() {
+		// The offsets of the outputs are different, so only
+		// the sequence number applies to both of them
+		opts.From = 0
+		streamCh, streamCancel = job.StreamCombined(opts)
+	} else if req.StreamErrors {
+		source = api.OutputSource_STDERR
+		streamCh, streamCancel = job.StreamStderr(opts)
 	} else {
-		streamCh, streamCancel = job.StreamStdout()
+		streamCh, streamCancel = job.StreamStdout(opts)
 	}
 	defer streamCancel()
+	if filter != nil {
+		streamCh = tw.FilterLines(stream.Context(), streamCh, *filter)
+	}
 
 	for {
 		select {
@@ -131,9 +352,24 @@ func (s *TWServer) Stream(req *api.StreamRequest, stream api.TeleWorker_StreamSe
 				// so we can just safely return
 				return nil
 			}
+			if res.Err != nil {
+				// The stream can be resumed from the offset
+				return status.Error(codes.ResourceExhausted, res.Err.Error())
+			}
 
 			resp := &api.StreamResponse{
-				OutStream: res,
+				OutStream: res.Data,
+				Offset:    res.Offset,
+				Truncated: res.Truncated,
+				Source:    source,
+				Seq:       res.Seq,
+				Size:      res.Size,
+			}
+			if !res.Time.IsZero() {
+				resp.Timestamp = timestamppb.New(res.Time)
+			}
+			if req.GetCombined() && res.Source == tw.SourceStderr {
+				resp.Source = api.OutputSource_STDERR
 			}
 
 			err := stream.Send(resp)
@@ -147,3 +383,277 @@ func (s *TWServer) Stream(req *api.StreamRequest, stream api.TeleWorker_StreamSe
 		}
 	}
 }
+
+// lineFilter returns the filter of the output lines requested,
+// nil if the whole output is to be streamed
+func lineFilter(req *api.StreamRequest) (*tw.LineFilter, error) {
+	if req.GetInclude() == "" && req.GetExclude() == "" && len(req.GetWhere()) == 0 && len(req.GetFields()) == 0 {
+		return nil, nil
+	}
+
+	filter := &tw.LineFilter{
+		Fields:  req.GetFields(),
+		Context: int(req.GetContextLines()),
+	}
+	for _, expr := range req.GetWhere() {
+		p, err := tw.ParsePredicate(expr)
+		if err != nil {
+			return nil, &InvalidFilter{expr: expr, err: err}
+		}
+		filter.Where = append(filter.Where, p)
+	}
+	if expr := req.GetInclude(); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, &InvalidFilter{expr: expr, err: err}
+		}
+		filter.Include = re
+	}
+	if expr := req.GetExclude(); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, &InvalidFilter{expr: expr, err: err}
+		}
+		filter.Exclude = re
+	}
+	return filter, nil
+}
+
+// logFormat converts the log format of the request
+func logFormat(format api.LogFormat) tw.LogFormat {
+	if format == api.LogFormat_JSON {
+		return tw.LogJSON
+	}
+	return tw.LogText
+}
+
+// DownloadLogs streams the gzipped tar archive of the job output and metadata
+func (s *TWServer) DownloadLogs(req *api.DownloadLogsRequest, stream api.TeleWorker_DownloadLogsServer) error {
+	id := req.GetJobId()
+	job, err := s.store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(&archiveWriter{stream: stream}, archiveChunkSize)
+	err = job.ExportLogs(stream.Context(), w)
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// archiveChunkSize is the size of the pieces the logs archive is sent in
+const archiveChunkSize = 32 * 1024
+
+// archiveWriter sends everything written to it as the logs archive pieces
+type archiveWriter struct {
+	stream api.TeleWorker_DownloadLogsServer
+}
+
+func (w *archiveWriter) Write(p []byte) (int, error) {
+	err := w.stream.Send(&api.DownloadLogsResponse{Data: p})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Attach connects the user to the job terminal: the output is streamed back
+// as it is produced and the input is passed to the job as if it was typed
+func (s *TWServer) Attach(stream api.TeleWorker_AttachServer) error {
+	user, ok := UsernameFromCtx(stream.Context())
+	if !ok {
+		return &UnauthorizedReq{}
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	id := first.GetJobId()
+	if id == "" {
+		return &MissingJobID{}
+	}
+
+	job, err := s.store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	// Being attached means being able to do anything with
+	// the job, so the same rules as for stopping it apply
+	if user.Name != job.User {
+		return &AccessDenied{}
+	}
+
+	outCh, attachCancel, err := job.Attach()
+	if err != nil {
+		return err
+	}
+	defer attachCancel()
+
+	inputErr := make(chan error, 1)
+	go func() {
+		inputErr <- passInput(stream, job)
+	}()
+
+	for {
+		select {
+		case res, ok := <-outCh:
+			if !ok {
+				// The job is over, nothing left to do here
+				return nil
+			}
+
+			err := stream.Send(&api.AttachResponse{
+				OutStream: res.Data,
+			})
+			if err != nil {
+				return err
+			}
+		case err := <-inputErr:
+			if err != nil {
+				return err
+			}
+			// The user has closed the input, but still
+			// might want to see how the job proceeds
+			inputErr = nil
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// passInput passes the attach stream messages to the job
+// until the user closes the stream
+func passInput(stream api.TeleWorker_AttachServer, job *tw.Job) error {
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch payload := msg.GetPayload().(type) {
+		case *api.AttachRequest_Stdin:
+			_, err = job.WriteInput(payload.Stdin)
+		case *api.AttachRequest_Resize:
+			err = job.Resize(
+				uint16(payload.Resize.GetRows()),
+				uint16(payload.Resize.GetCols()),
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Exec runs an additional command in the resource context of the job
+// and streams its output back, finishing the stream with its exit code.
+// The command is not submitted to the queue and is not counted against
+// the caps of the owner, as it shares the resource limits of the job
+// and lives only as long as the stream does
+func (s *TWServer) Exec(req *api.ExecRequest, stream api.TeleWorker_ExecServer) error {
+	user, ok := UsernameFromCtx(stream.Context())
+	if !ok {
+		return &UnauthorizedReq{}
+	}
+
+	id := req.GetJobId()
+	job, err := s.store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if user.Name != job.User {
+		return &AccessDenied{}
+	}
+
+	execJob, err := job.Exec(req.GetCommand(), req.GetArgs())
+	if err != nil {
+		return err
+	}
+	// The command is not kept in the storage, so its output is
+	// freed, along with the log budget, once the streams are drained.
+	// If the command could not be stopped, the handler is not held
+	// until it exits and the output is freed in the background then
+	stopped := true
+	defer func() {
+		if !stopped {
+			go func() {
+				<-execJob.Done()
+				execJob.Release()
+			}()
+			return
+		}
+		<-execJob.Done()
+		execJob.Release()
+	}()
+
+	outCh, outCancel := execJob.StreamStdout(tw.StreamOptions{})
+	defer outCancel()
+	errCh, errCancel := execJob.StreamStderr(tw.StreamOptions{})
+	defer errCancel()
+
+	for outCh != nil || errCh != nil {
+		var resp *api.ExecResponse
+
+		select {
+		case res, ok := <-outCh:
+			if !ok {
+				outCh = nil
+				continue
+			}
+			resp = &api.ExecResponse{
+				Payload: &api.ExecResponse_OutStream{OutStream: res.Data},
+			}
+		case res, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			resp = &api.ExecResponse{
+				Payload: &api.ExecResponse_ErrStream{ErrStream: res.Data},
+			}
+		case <-stream.Context().Done():
+			// Nobody is interested in the command anymore
+			stopped = stopExec(execJob)
+			return stream.Context().Err()
+		}
+
+		err := stream.Send(resp)
+		if err != nil {
+			stopped = stopExec(execJob)
+			return err
+		}
+	}
+
+	<-execJob.Done()
+	return stream.Send(&api.ExecResponse{
+		Payload: &api.ExecResponse_ExitCode{
+			ExitCode: int32(execJob.Status().ExitCode),
+		},
+	})
+}
+
+// stopExec stops the command nobody is interested in anymore and reports
+// whether it is over or going to be soon. The command which has already
+// exited, while its output is still being streamed, is not an error
+func stopExec(job *tw.Job) bool {
+	err := job.Stop()
+	if err == nil {
+		return true
+	}
+
+	select {
+	case <-job.Done():
+		return true
+	default:
+		log.Printf("error stopping exec job %s: %v", job.ID, err)
+		return false
+	}
+}
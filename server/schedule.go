@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/spirifoxy/teleworker/server/internal/scheduler"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CreateSchedule registers the job to be started regularly on behalf of the user.
+// The jobs started by the schedule are ordinary jobs owned by the user
+func (s *TWServer) CreateSchedule(ctx context.Context, req *api.CreateScheduleRequest) (*api.CreateScheduleResponse, error) {
+	user, ok := UsernameFromCtx(ctx)
+	if !ok {
+		return nil, &UnauthorizedReq{}
+	}
+
+	err := s.checkPriority(user, req.GetJob())
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := scheduler.New(user.Name, req.GetCron(), req.GetTimezone(), req.GetOverlapPolicy(), req.GetJob())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.scheduler.Add(sc, func(req *api.StartRequest) (*tw.Job, error) {
+		return s.startJob(user, req, inputOptions(req)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.CreateScheduleResponse{
+		ScheduleId: sc.ID.String(),
+	}, nil
+}
+
+// ListSchedules returns the schedules created by the user
+func (s *TWServer) ListSchedules(ctx context.Context, req *api.ListSchedulesRequest) (*api.ListSchedulesResponse, error) {
+	user, ok := UsernameFromCtx(ctx)
+	if !ok {
+		return nil, &UnauthorizedReq{}
+	}
+
+	schedules := s.scheduler.List(user.Name)
+	resp := &api.ListSchedulesResponse{
+		Schedules: make([]*api.Schedule, 0, len(schedules)),
+	}
+	for _, sc := range schedules {
+		schedule := &api.Schedule{
+			ScheduleId:    sc.ID.String(),
+			Cron:          sc.Spec,
+			Timezone:      sc.Timezone,
+			OverlapPolicy: sc.Overlap,
+			Job:           sc.Request,
+			NextRun:       timestamppb.New(s.scheduler.NextRun(sc)),
+		}
+		for _, run := range sc.History() {
+			scheduled := &api.ScheduledRun{
+				StartedAt: timestamppb.New(run.StartedAt),
+				JobId:     run.JobID,
+			}
+			if run.Err != nil {
+				scheduled.Error = run.Err.Error()
+			}
+			schedule.History = append(schedule.History, scheduled)
+		}
+		resp.Schedules = append(resp.Schedules, schedule)
+	}
+
+	return resp, nil
+}
+
+// DeleteSchedule stops starting new jobs by the schedule,
+// the jobs that are already running are not affected
+func (s *TWServer) DeleteSchedule(ctx context.Context, req *api.DeleteScheduleRequest) (*api.DeleteScheduleResponse, error) {
+	user, ok := UsernameFromCtx(ctx)
+	if !ok {
+		return nil, &UnauthorizedReq{}
+	}
+
+	sc, err := s.scheduler.Get(req.GetScheduleId())
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Name != sc.User {
+		return nil, &AccessDenied{}
+	}
+
+	if err := s.scheduler.Remove(sc.ID.String()); err != nil {
+		return nil, err
+	}
+
+	return &api.DeleteScheduleResponse{}, nil
+}
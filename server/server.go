@@ -3,28 +3,71 @@ package main
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/alexflint/go-arg"
 	api "github.com/spirifoxy/teleworker/internal/api/v1"
 	cg "github.com/spirifoxy/teleworker/pkg/cgroup"
 	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
 	"github.com/spirifoxy/teleworker/server/internal/auth"
+	"github.com/spirifoxy/teleworker/server/internal/queue"
+	"github.com/spirifoxy/teleworker/server/internal/scheduler"
 	"github.com/spirifoxy/teleworker/server/internal/storage"
+	"github.com/spirifoxy/teleworker/server/internal/workflow"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	// Registers the gzip compressor, so the streams requested
+	// with the compression are responded to compressed as well
+	_ "google.golang.org/grpc/encoding/gzip"
 )
 
 type TWServer struct {
 	api.UnimplementedTeleWorkerServer
 
-	store  storage.Storage
-	cgroup cg.Cgroup
+	store     storage.Storage
+	workflows *workflow.Memory
+	scheduler *scheduler.Scheduler
+	queue     *queue.Queue
+	cgroup    cg.Cgroup
+
+	// elevated contains the users allowed to start
+	// the jobs with priority higher than the default one
+	elevated map[string]bool
+	// admins contains the users allowed to list the jobs of the others
+	admins map[string]bool
+	// logOptions limit the output kept in memory for every job
+	logOptions []tw.Option
+	// shimDir is the directory the shims of the jobs keep their
+	// state in, the jobs are launched directly if it is not set
+	shimDir string
+}
+
+// Config contains the server settings provided on launch
+type Config struct {
+	MaxJobs        int      `arg:"--max-jobs" help:"maximum number of jobs running at the same time, 0 means unlimited"`
+	MaxJobsPerUser int      `arg:"--max-jobs-per-user" help:"maximum number of jobs running at the same time for every user, 0 means unlimited"`
+	QueueOrder     string   `arg:"--queue-order" default:"fifo" help:"order the queued jobs are started in: fifo or priority"`
+	PriorityUsers  []string `arg:"--priority-users" help:"users allowed to request elevated priority, i.e. negative nice values"`
+	AdminUsers     []string `arg:"--admin-users" help:"users allowed to list the jobs of all the users"`
+	LogLimitMB     int      `arg:"--log-limit-mb" default:"10" help:"amount of the latest output kept in memory for each stream of the job, 0 means unlimited"`
+	LogBudgetMB    int64    `arg:"--log-budget-mb" default:"1024" help:"amount of the output kept in memory for all the jobs, 0 means unlimited"`
+	CompressLogs   bool     `arg:"--compress-logs" help:"keep the output in memory compressed, the limits apply to the compressed size then"`
+	DataDir        string   `arg:"--data-dir" help:"directory the complete output of the jobs is written to, only the latest part of it is kept in memory if not set"`
+	Storage        string   `arg:"--storage" default:"memory" help:"where the jobs are kept: memory, or file to keep them in the data directory across the restarts"`
+	Shim           bool     `arg:"--shim" help:"launch the jobs through the supervisor process, so they keep running across the restarts, requires file storage"`
+	StreamQueueKB  int      `arg:"--stream-queue-kb" default:"1024" help:"amount of the live output queued for every stream, 0 means unlimited"`
+	SlowStream     string   `arg:"--slow-stream" default:"drop" help:"what happens to the stream once its queue is full: drop the oldest output or disconnect"`
+	MetricsAddr    string   `arg:"--metrics-addr" help:"address the metrics are served at under /debug/vars, disabled if not set"`
 }
 
-func NewTWServer() (*TWServer, error) {
+func NewTWServer(config *Config) (*TWServer, error) {
 	const defaultTTL = 5 * time.Minute
 
 	cgroup, err := cg.NewV1Runner()
@@ -32,17 +75,128 @@ func NewTWServer() (*TWServer, error) {
 		return nil, err
 	}
 
+	ordering := queue.FIFO
+	switch config.QueueOrder {
+	case "fifo":
+	case "priority":
+		ordering = queue.Priority
+	default:
+		return nil, fmt.Errorf("unknown queue order %s", config.QueueOrder)
+	}
+
+	elevated := make(map[string]bool)
+	for _, user := range config.PriorityUsers {
+		elevated[user] = true
+	}
+
+	admins := make(map[string]bool)
+	for _, user := range config.AdminUsers {
+		admins[user] = true
+	}
+
+	var logOptions []tw.Option
+	if config.LogLimitMB > 0 {
+		logOptions = append(logOptions, tw.WithLogLimit(config.LogLimitMB*1024*1024))
+	}
+	if config.LogBudgetMB > 0 {
+		logOptions = append(logOptions, tw.WithLogBudget(tw.NewLogBudget(config.LogBudgetMB*1024*1024)))
+	}
+	if config.CompressLogs {
+		logOptions = append(logOptions, tw.WithLogCompression())
+	}
+	if config.StreamQueueKB > 0 {
+		policy := tw.DropOldest
+		switch config.SlowStream {
+		case "drop":
+		case "disconnect":
+			policy = tw.Disconnect
+		default:
+			return nil, fmt.Errorf("unknown slow stream policy %s", config.SlowStream)
+		}
+		logOptions = append(logOptions, tw.WithStreamQueue(config.StreamQueueKB*1024, policy))
+	}
+	if config.DataDir != "" {
+		logDir := filepath.Join(config.DataDir, "logs")
+		err := os.MkdirAll(logDir, 0700)
+		if err != nil {
+			return nil, fmt.Errorf("error creating log directory: %w", err)
+		}
+		logOptions = append(logOptions, tw.WithLogDir(logDir))
+	}
+
+	var shimDir string
+	restoreOptions := logOptions
+	if config.Shim {
+		if config.Storage != "file" {
+			return nil, fmt.Errorf("shim requires file storage, otherwise the jobs are not adopted after the restart")
+		}
+		shimDir = filepath.Join(config.DataDir, "shims")
+		restoreOptions = append(restoreOptions[:len(restoreOptions):len(restoreOptions)], tw.WithShim(shimDir))
+	}
+
+	storeOptions := []storage.Option{
+		storage.WithTTL(defaultTTL),
+		storage.WithEvictionHook(func(job *tw.Job) {
+			log.Printf("job %s is evicted from the storage", job.ID)
+		}),
+	}
+	var store storage.Storage
+	switch config.Storage {
+	case "memory":
+		store = storage.NewMemStorage(storeOptions...)
+	case "file":
+		if config.DataDir == "" {
+			return nil, fmt.Errorf("file storage requires the data directory")
+		}
+		// The restored jobs read their output from the same
+		// directory it was written to before the restart
+		store, err = storage.NewFileStorage(filepath.Join(config.DataDir, "jobs"), restoreOptions, storeOptions...)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown storage %s", config.Storage)
+	}
+
+	q := queue.New(
+		queue.WithMaxRunning(config.MaxJobs),
+		queue.WithMaxPerUser(config.MaxJobsPerUser),
+		queue.WithOrdering(ordering),
+	)
+	// The jobs adopted from the shims are still running after the
+	// restart, so they take their slots until they are over
+	adopted, _, err := store.List(storage.Query{
+		Filter: storage.Filter{
+			Statuses: []api.JobStatus{api.JobStatus_ALIVE, api.JobStatus_RESTARTING},
+		},
+		Order: storage.OldestFirst,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range adopted {
+		q.Adopt(job)
+	}
+
 	return &TWServer{
-		store: storage.NewMemStorage(
-			storage.WithTTL(defaultTTL),
-		),
-		cgroup: cgroup,
+		store:      store,
+		workflows:  workflow.NewMemStorage(workflow.WithTTL(defaultTTL)),
+		scheduler:  scheduler.NewScheduler(),
+		queue:      q,
+		cgroup:     cgroup,
+		elevated:   elevated,
+		admins:     admins,
+		logOptions: logOptions,
+		shimDir:    shimDir,
 	}, nil
 }
 
 func main() {
 	tw.InternalCallHandle()
 
+	var config Config
+	arg.MustParse(&config)
+
 	const port = ":50051"
 
 	listener, err := net.Listen("tcp", port)
@@ -50,11 +204,19 @@ func main() {
 		log.Fatalf("failed to create listener on port %s: %s", port, err)
 	}
 
-	twServer, err := NewTWServer()
+	twServer, err := NewTWServer(&config)
 	if err != nil {
 		log.Fatalf("error registering internal services: %s", err)
 	}
 
+	if config.MetricsAddr != "" {
+		// The metrics are published by expvar to the default mux
+		go func() {
+			err := http.ListenAndServe(config.MetricsAddr, nil)
+			log.Printf("metrics server is stopped: %s", err)
+		}()
+	}
+
 	grpcServer := grpc.NewServer(
 		credsOption(),
 		grpc.StreamInterceptor(auth.StreamServerInterceptor(auth.CertAuthFunc)),
@@ -14,6 +14,7 @@ import (
 	api "github.com/spirifoxy/teleworker/internal/api/v1"
 	"github.com/spirifoxy/teleworker/pkg/teleworker"
 	"github.com/spirifoxy/teleworker/server/internal/auth"
+	"github.com/spirifoxy/teleworker/server/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc"
@@ -35,9 +36,18 @@ func (s *StoreMocked) Put(*teleworker.Job) error {
 	return nil
 }
 
+func (s *StoreMocked) List(storage.Query) ([]*teleworker.Job, string, error) {
+	return nil, "", nil
+}
+
+func (s *StoreMocked) Watch(ctx context.Context) <-chan *teleworker.Job {
+	return nil
+}
+
 var twServer *TWServer
 
-func init() {
+// initAuthServer starts the server with the real authentication
+func initAuthServer() {
 	listener, err := net.Listen("tcp", ":50052")
 	if err != nil {
 		log.Fatalf("server exited with error: %v", err)
@@ -131,3 +141,14 @@ func clientCredsOption() grpc.DialOption {
 
 	return grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg))
 }
+
+func TestStopExecIgnoresExitedCommand(t *testing.T) {
+	job, err := teleworker.NewJob("true", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, job.Start())
+	<-job.Done()
+
+	// Only the output might be left to stream, there is nothing to stop
+	assert.True(t, stopExec(job))
+	job.Release()
+}
@@ -0,0 +1,36 @@
+Feature: attach to the job
+    In order to interact with my command
+    As an end user
+    I need to attach to the job terminal
+
+    Scenario: should attach to the job with terminal
+    Given I pass my command sleep
+    And I pass command argument 2
+    And the job with terminal was created
+    When I try to attach to the job
+    Then the response is success
+
+    Scenario: should pass the typed input to the job and show its output
+    Given I pass my command sh
+    And I pass command argument -c
+    And I pass command argument read line; echo "got $line"
+    And the job with terminal was created
+    When I attach to the job
+    And I type hello into the terminal
+    Then I see got hello in the terminal
+
+    Scenario: should resize the job terminal
+    Given I pass my command sh
+    And I pass command argument -c
+    And I pass command argument read line; stty size
+    And the job with terminal was created
+    When I attach to the job
+    And I resize the terminal to 30 rows and 100 columns
+    And I type anything into the terminal
+    Then I see 30 100 in the terminal
+
+    Scenario: should fail to attach to the job without terminal
+    Given I pass my command cat
+    And the job was created
+    When I try to attach to the job
+    Then the response is error
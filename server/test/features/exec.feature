@@ -0,0 +1,25 @@
+Feature: exec in the job
+    In order to inspect my running command
+    As an end user
+    I need to run another command next to it
+
+    Scenario: should exec command in the job
+    Given I pass my command sleep
+    And I pass command argument 2
+    And the job was created
+    When I try to exec echo 1 in the job
+    Then the response is success
+    And I see the exec output is 1
+    And I see the exec exit code is 0
+
+    Scenario: should pass exit code of the exec command
+    Given I pass my command sleep
+    And I pass command argument 2
+    And the job was created
+    When I try to exec false in the job
+    Then the response is success
+    And I see the exec exit code is 1
+
+    Scenario: should fail to exec in unexistent job
+    When I try to exec echo 1 in some random job
+    Then the response is error
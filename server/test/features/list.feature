@@ -0,0 +1,44 @@
+Feature: list the jobs
+    In order to find the jobs I have started
+    As an end user
+    I need to list my jobs filtered by their properties
+
+    Scenario: should list the jobs selected by label newest first
+    Given the job first printing one was created with label app=list-1
+    And the job second printing two was created with label app=list-1
+    And the job other printing three was created with label app=list-2
+    When I try to list the jobs with label app=list-1
+    Then the response is success
+    And I see the listed jobs print two, one
+    And I see there are no more jobs to list
+
+    Scenario: should list the jobs page by page
+    Given the job first printing one was created with label app=list-3
+    And the job second printing two was created with label app=list-3
+    And the job third printing three was created with label app=list-3
+    When I try to list 2 jobs with label app=list-3 oldest first
+    Then the response is success
+    And I see the listed jobs print one, two
+    And I see there are more jobs to list
+    When I try to list the next page
+    Then the response is success
+    And I see the listed jobs print three
+    And I see there are no more jobs to list
+
+    Scenario: should list the jobs having the status
+    Given the job first printing one was created with label app=list-4
+    And I wait for the jobs with label app=list-4 to be over
+    When I try to list the alive jobs with label app=list-4
+    Then the response is success
+    And I see the listed jobs print nothing
+    When I try to list the finished jobs with label app=list-4
+    Then the response is success
+    And I see the listed jobs print one
+
+    Scenario: should not list the jobs of all the users for ordinary user
+    When I try to list the jobs of all the users
+    Then the response is error
+
+    Scenario: should not list the jobs with invalid page token
+    When I try to list the jobs with page token garbage
+    Then the response is error
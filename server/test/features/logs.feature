@@ -0,0 +1,32 @@
+Feature: download the job logs
+    In order to attach the job output to the incident ticket
+    As an end user
+    I need to download the complete job logs as an archive
+
+    Scenario: should download the logs of the finished job
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo hello; echo oops >&2; exit 3
+    And the job was created
+    And I wait for a second
+    When I try to download the job logs
+    Then the response is success
+    And I see the archive file stdout.log is hello
+    And I see the archive file stderr.log contains oops
+    And I see the archived exit code is 3
+    And I see the archived times are in order
+
+    Scenario: should download the logs of the running job
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo started; sleep 10
+    And the job was created
+    And I wait for a second
+    When I try to download the job logs
+    Then the response is success
+    And I see the archive file stdout.log is started
+    And I see the archived exit time is absent
+
+    Scenario: should not download the logs of some random job
+    When I try to download the logs of some random job
+    Then the response is error
@@ -0,0 +1,35 @@
+Feature: stream the output of several jobs
+    In order to see what all the shards of my fan-out print
+    As an end user
+    I need to stream the output of several jobs together
+
+    Scenario: should stream the jobs selected by label
+    Given the job shard-1 printing hello was created with label app=many-1
+    And the job shard-2 printing world was created with label app=many-1
+    And the job other printing nothing was created with label app=many-2
+    And I wait for a second
+    When I try to stream the jobs with label app=many-1
+    Then the response is success
+    And I see the streamed job lines are shard-1: hello, shard-2: world
+
+    Scenario: should add the jobs started while following
+    Given the job shard-1 printing first was created with label app=many-3
+    And I wait for a second
+    When I start following the jobs with label app=many-3
+    And the job shard-2 printing second was created with label app=many-3
+    And I wait for a second
+    Then the response is success
+    And I see the streamed job lines are shard-1: first, shard-2: second
+
+    Scenario: should follow the jobs by default as the older clients expect
+    Given the job shard-1 printing first was created with label app=many-4
+    And I wait for a second
+    When I start streaming the jobs with label app=many-4 without setting the follow flag
+    And the job shard-2 printing second was created with label app=many-4
+    And I wait for a second
+    Then the response is success
+    And I see the streamed job lines are shard-1: first, shard-2: second
+
+    Scenario: should not stream without selecting the jobs
+    When I try to stream the jobs without selecting any
+    Then the response is error
@@ -0,0 +1,29 @@
+Feature: priority of the job
+    In order to let more important commands run faster
+    As an end user
+    I need to start the job with some priority
+
+    Scenario: should run the job with lowered priority
+    Given I pass my command sleep
+    And I pass command argument 10
+    And I pass priority 5
+    And the job was created
+    And I wait for a second
+    When I try to get status of the job
+    Then the response is success
+    And I see the job is still running
+    And I see the job nice value is 5
+
+    Scenario: should not allow elevated priority for ordinary user
+    Given I pass my command sleep
+    And I pass command argument 10
+    And I pass priority -5
+    When I try to create new job
+    Then the response is error
+
+    Scenario: should reject priority out of range
+    Given I pass my command sleep
+    And I pass command argument 10
+    And I pass priority 20
+    When I try to create new job
+    Then the response is error
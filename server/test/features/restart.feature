@@ -0,0 +1,32 @@
+Feature: restart the job
+    In order to keep my command running
+    As an end user
+    I need the job to be restarted when it exits
+
+    Scenario: should restart the failed job
+    Given I pass my command false
+    And I pass restart policy ON_FAILURE with 2 restarts
+    And the job was created
+    And I wait for the job to be over
+    When I try to get status of the job
+    Then the response is success
+    And I see the job is finished
+    And I see the job attempt is 3
+
+    Scenario: should not restart the succeeded job
+    Given I pass my command true
+    And I pass restart policy ON_FAILURE with 2 restarts
+    And the job was created
+    And I wait for the job to be over
+    When I try to get status of the job
+    Then the response is success
+    And I see the job is finished
+    And I see the job attempt is 1
+
+    Scenario: should stop the job waiting for restart
+    Given I pass my command false
+    And I pass restart policy ALWAYS with 0 restarts
+    And the job was created
+    And I wait for a second
+    When I try to stop the job
+    Then the response is success
@@ -0,0 +1,36 @@
+Feature: schedule the job
+    In order to run the command regularly
+    As an end user
+    I need to create the schedule
+
+    Scenario: should create, list and delete the schedule
+    When I pass my command echo
+    And I create the schedule "0 2 * * *" in timezone Europe/Prague
+    Then the response is success
+    When I try to list my schedules
+    Then the response is success
+    And I see the schedule in the list
+    When I try to delete the schedule
+    Then the response is success
+    When I try to list my schedules
+    Then I do not see the schedule in the list
+
+    Scenario: should reject invalid cron expression
+    When I pass my command echo
+    And I create the schedule "0 2 * *" in timezone UTC
+    Then the response is error
+
+    Scenario: should reject unknown timezone
+    When I pass my command echo
+    And I create the schedule "0 2 * * *" in timezone Mars/Olympus
+    Then the response is error
+
+    Scenario: should not allow elevated priority for ordinary user
+    When I pass my command echo
+    And I pass priority -5
+    And I create the schedule "0 2 * * *" in timezone UTC
+    Then the response is error
+
+    Scenario: should not delete unknown schedule
+    When I try to delete some random schedule
+    Then the response is error
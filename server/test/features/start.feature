@@ -17,4 +17,28 @@ Feature: start new job
     And I pass command argument cat /proc/cpuinfo | egrep '^model name' | uniq
     And I try to create new job
     Then the response is success
-    And I get the job uuid
\ No newline at end of file
+    And I get the job uuid
+
+    Scenario: should create new job with input
+    When I pass my command cat
+    And I pass command input some data for the job
+    And I try to create new job
+    Then the response is success
+    And I get the job uuid
+    And I see the job output is exactly the input
+
+    Scenario: should create new job streaming the input
+    When I pass my command cat
+    And I pass command input some data for the job
+    And I try to create new job streaming the input
+    Then the response is success
+    And I get the job uuid
+    And I got the job uuid before the input was sent
+    And I see the job output is exactly the input
+
+    Scenario: should close the input of the job streaming no input
+    When I pass my command cat
+    And I try to create new job streaming the input
+    Then the response is success
+    And I get the job uuid
+    And I see the job output is exactly the input
\ No newline at end of file
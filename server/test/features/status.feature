@@ -15,7 +15,7 @@ Feature: status of the job
     Given I pass my command echo
     And I pass command argument 1
     And the job was created
-    And I wait for a second
+    And I wait for the job to be over
     When I try to get status of the job
     Then the response is success
     And I see the job is finished
@@ -0,0 +1,113 @@
+Feature: stream the job output
+    In order to see what my command prints
+    As an end user
+    I need to stream the job output
+
+    Scenario: should stream the whole output
+    Given I pass my command echo
+    And I pass command argument hello world
+    And the job was created
+    And I wait for a second
+    When I try to stream the job output
+    Then the response is success
+    And I see the streamed output is hello world
+    And I see the streamed output starts at offset 0
+
+    Scenario: should stream the output compressed
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument seq 1 1000 | tail -n 3
+    And the job was created
+    And I wait for a second
+    When I stream with compression
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is 998 999 1000
+
+    Scenario: should resume the stream from offset
+    Given I pass my command echo
+    And I pass command argument hello world
+    And the job was created
+    And I wait for a second
+    When I stream from offset 6
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is world
+    And I see the streamed output starts at offset 6
+
+    Scenario: should stream the last lines of the running job without following
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument seq 1 5; sleep 10
+    And the job was created
+    And I wait for a second
+    When I stream the last 2 lines
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is 4 5
+
+    Scenario: should follow the output until the job is over
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo started; sleep 1; echo finished
+    And the job was created
+    When I stream following the output
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is started finished
+
+    Scenario: should follow the output by default as the older clients expect
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo started; sleep 1; echo finished
+    And the job was created
+    When I stream without setting the follow flag
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is started finished
+
+    Scenario: should stream both outputs in the order they were produced
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo one; sleep 0.2; echo two >&2; sleep 0.2; echo three
+    And the job was created
+    And I wait for a second
+    When I stream both outputs
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is one two three
+    And I see the streamed sources are stdout stderr stdout
+
+    Scenario: should stream the output produced since the given moment
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo one; sleep 0.5; echo two
+    And the job was created
+    And I wait for a second
+    When I stream the output produced in the last 700ms
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is two
+    And I see the streamed chunks have timestamps
+
+    Scenario: should stream only the lines matching the filter
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument printf 'a\nerror 1\nb\nc\nd\nerror 2\ndebug\n'
+    And the job was created
+    And I wait for a second
+    When I stream the lines matching ^(error|debug)
+    And I stream the lines not matching debug
+    And I stream 1 lines around them
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is a error 1 b d error 2 debug
+
+    Scenario: should not stream the output with invalid filter
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo hello
+    And the job was created
+    When I stream the lines matching (error
+    And I try to stream the job output
+    Then the response is error
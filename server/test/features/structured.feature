@@ -0,0 +1,38 @@
+Feature: structured output of the job
+    In order to find the important events in the output of the job
+    As an end user
+    I need to filter the JSON lines of the job output by their fields
+
+    Scenario: should stream the selected fields of the matching lines
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo '{"level":"info","latency_ms":20,"msg":"fast"}'; echo '{"level":"error","latency_ms":700,"msg":"slow"}'; echo '{"level":"error","latency_ms":90,"msg":"failed"}'
+    And I pass log format json
+    And the job was created
+    And I wait for a second
+    When I stream the lines where level=error
+    And I stream the lines where latency_ms>500
+    And I stream only the fields msg
+    And I try to stream the job output
+    Then the response is success
+    And I see the streamed output is {"msg":"slow"}
+
+    Scenario: should report the lines which are not JSON
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo '{"level":"info"}'; echo broken
+    And I pass log format json
+    And the job was created
+    And I wait for a second
+    When I try to get status of the job
+    Then the response is success
+    And I see the job has 1 log parse error
+
+    Scenario: should not filter the fields of the plain text output
+    Given I pass my command bash
+    And I pass command argument -c
+    And I pass command argument echo hello
+    And the job was created
+    When I stream the lines where level=error
+    And I try to stream the job output
+    Then the response is error
@@ -0,0 +1,53 @@
+Feature: run the workflow
+    In order to run the chain of dependent commands
+    As an end user
+    I need to submit the workflow
+
+    Scenario: should run all the steps of the workflow
+    When I submit the workflow
+    | name      | command | depends_on       | policy |
+    | extract   | true    |                  |        |
+    | transform | true    | extract          |        |
+    | enrich    | true    | extract          |        |
+    | load      | true    | transform enrich |        |
+    Then the response is success
+    When I wait for the workflow to be over
+    Then I see the workflow is SUCCEEDED
+    And I see the step load is SUCCEEDED
+
+    Scenario: should cancel the steps depending on the failed one
+    When I submit the workflow
+    | name      | command | depends_on | policy        |
+    | extract   | true    |            |               |
+    | transform | false   | extract    |               |
+    | load      | true    | transform  |               |
+    | cleanup   | true    | load       | ON_COMPLETION |
+    Then the response is success
+    When I wait for the workflow to be over
+    Then I see the workflow is FAILED
+    And I see the step transform is FAILED
+    And I see the step load is CANCELLED
+    And I see the step cleanup is SUCCEEDED
+
+    Scenario: should pass the input to the steps of the workflow
+    Given I pass command input hello
+    When I submit the workflow
+    | name  | command       | depends_on | policy |
+    | check | grep -q hello |            |        |
+    Then the response is success
+    When I wait for the workflow to be over
+    Then I see the workflow is SUCCEEDED
+
+    Scenario: should reject the workflow with cycle
+    When I submit the workflow
+    | name      | command | depends_on | policy |
+    | extract   | true    | load       |        |
+    | load      | true    | extract    |        |
+    Then the response is error
+
+    Scenario: should not allow elevated priority for ordinary user
+    Given I pass priority -5
+    When I submit the workflow
+    | name      | command | depends_on | policy |
+    | extract   | true    |            |        |
+    Then the response is error
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+
+	api "github.com/spirifoxy/teleworker/internal/api/v1"
+	tw "github.com/spirifoxy/teleworker/pkg/teleworker"
+	"github.com/spirifoxy/teleworker/server/internal/workflow"
+)
+
+// SubmitWorkflow validates the workflow and starts running it in the background.
+// The jobs of the workflow are ordinary jobs owned by the user, so they
+// can be managed separately from the workflow as well
+func (s *TWServer) SubmitWorkflow(ctx context.Context, req *api.SubmitWorkflowRequest) (*api.SubmitWorkflowResponse, error) {
+	user, ok := UsernameFromCtx(ctx)
+	if !ok {
+		return nil, &UnauthorizedReq{}
+	}
+
+	steps := make([]*workflow.Step, 0, len(req.GetSteps()))
+	for _, step := range req.GetSteps() {
+		err := s.checkPriority(user, step.GetJob())
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, &workflow.Step{
+			Name:      step.GetName(),
+			Request:   step.GetJob(),
+			DependsOn: step.GetDependsOn(),
+			Policy:    step.GetPolicy(),
+		})
+	}
+
+	w, err := workflow.New(user.Name, steps)
+	if err != nil {
+		return nil, err
+	}
+	s.workflows.Put(w)
+
+	w.Run(func(req *api.StartRequest) (*tw.Job, error) {
+		return s.startJob(user, req, inputOptions(req)...)
+	})
+
+	return &api.SubmitWorkflowResponse{
+		WorkflowId: w.ID.String(),
+	}, nil
+}
+
+func (s *TWServer) WorkflowStatus(ctx context.Context, req *api.WorkflowStatusRequest) (*api.WorkflowStatusResponse, error) {
+	user, ok := UsernameFromCtx(ctx)
+	if !ok {
+		return nil, &UnauthorizedReq{}
+	}
+
+	w, err := s.workflows.Get(req.GetWorkflowId())
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Name != w.User {
+		return nil, &AccessDenied{}
+	}
+
+	state, steps := w.Status()
+	resp := &api.WorkflowStatusResponse{
+		State: state,
+		Steps: make([]*api.WorkflowStepStatus, 0, len(steps)),
+	}
+	for _, step := range steps {
+		status := &api.WorkflowStepStatus{
+			Name:     step.Name,
+			State:    step.State,
+			JobId:    step.JobID,
+			ExitCode: int32(step.ExitCode),
+		}
+		if step.Err != nil {
+			status.Error = step.Err.Error()
+		}
+		resp.Steps = append(resp.Steps, status)
+	}
+
+	return resp, nil
+}